@@ -0,0 +1,187 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	// EnvelopePrefix 信封加密密文前缀，格式为 enc:v1:<key_id>:<加密的数据密钥>:<加密的数据>
+	EnvelopePrefix = "enc:v1:"
+	// MasterKeyEnv 未指定主密钥文件时读取主密钥的环境变量
+	MasterKeyEnv = "K8S_HELPER_MASTER_KEYS"
+	// masterKeySize 主密钥和数据密钥长度（AES-256）
+	masterKeySize = 32
+)
+
+// keyIDPattern 合法的主密钥 ID
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// KeyRing 主密钥集合，当前主密钥用于加密，其余密钥仅用于解密轮换前写入的数据
+type KeyRing struct {
+	primary string
+	keys    map[string][]byte
+}
+
+// ParseKeyRing 解析主密钥配置，每行（或逗号分隔）一个 <key_id>:<base64 编码的 32 字节密钥>，
+// 第一个为当前主密钥，# 开头的行为注释
+func ParseKeyRing(data string) (*KeyRing, error) {
+	k := &KeyRing{keys: map[string][]byte{}}
+	for _, entry := range strings.FieldsFunc(data, func(r rune) bool { return r == '\n' || r == ',' }) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("invalid master key entry, want <key_id>:<base64 key>")
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(key) != masterKeySize {
+			return nil, fmt.Errorf("master key %s must be %d bytes encoded in base64", id, masterKeySize)
+		}
+		if _, dup := k.keys[id]; dup {
+			return nil, fmt.Errorf("duplicate master key id %s", id)
+		}
+		if k.primary == "" {
+			k.primary = id
+		}
+		k.keys[id] = key
+	}
+	if k.primary == "" {
+		return nil, errors.New("no master key configured")
+	}
+	return k, nil
+}
+
+// LoadKeyRing 从文件读取密钥，path 为空时读取环境变量 env，均未配置时返回 nil
+func LoadKeyRing(path, env string) (*KeyRing, error) {
+	data := os.Getenv(env)
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		data = string(raw)
+	}
+	if strings.TrimSpace(data) == "" {
+		return nil, nil
+	}
+	return ParseKeyRing(data)
+}
+
+// Primary 返回当前主密钥 ID
+func (k *KeyRing) Primary() string {
+	return k.primary
+}
+
+// IsEncrypted 判断值是否为信封加密密文
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, EnvelopePrefix)
+}
+
+// Encrypt 使用随机数据密钥以 AES-GCM 加密 plain，数据密钥再由当前主密钥加密。
+// aad 为附加认证数据（如记录主键），解密时必须一致，防止密文被挪用到其他记录
+func (k *KeyRing) Encrypt(plain, aad []byte) (string, error) {
+	dek := make([]byte, masterKeySize)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	wrapped, err := seal(k.keys[k.primary], dek, []byte(k.primary))
+	if err != nil {
+		return "", err
+	}
+	data, err := seal(dek, plain, aad)
+	if err != nil {
+		return "", err
+	}
+	return EnvelopePrefix + k.primary + ":" + base64.RawStdEncoding.EncodeToString(wrapped) + ":" + base64.RawStdEncoding.EncodeToString(data), nil
+}
+
+// Decrypt 解密 Encrypt 生成的密文
+func (k *KeyRing) Decrypt(value string, aad []byte) ([]byte, error) {
+	id, wrapped, data, err := parseEnvelope(value)
+	if err != nil {
+		return nil, err
+	}
+	kek, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown master key %s", id)
+	}
+	dek, err := open(kek, wrapped, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key: %w", err)
+	}
+	plain, err := open(dek, data, aad)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt data: %w", err)
+	}
+	return plain, nil
+}
+
+// NeedsRotation 判断值是否需要重新加密：明文或不是由当前主密钥加密
+func (k *KeyRing) NeedsRotation(value string) bool {
+	id, _, _, err := parseEnvelope(value)
+	return err != nil || id != k.primary
+}
+
+// parseEnvelope 拆分密文为主密钥 ID、加密的数据密钥和加密的数据
+func parseEnvelope(value string) (string, []byte, []byte, error) {
+	if !IsEncrypted(value) {
+		return "", nil, nil, errors.New("value is not encrypted")
+	}
+	parts := strings.Split(strings.TrimPrefix(value, EnvelopePrefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, errors.New("malformed envelope")
+	}
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("malformed envelope: %w", err)
+	}
+	data, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("malformed envelope: %w", err)
+	}
+	return parts[0], wrapped, data, nil
+}
+
+// seal AES-GCM 加密，返回 nonce||密文
+func seal(key, plain, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, aad), nil
+}
+
+// open 解密 seal 的结果
+func open(key, data, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], aad)
+}
+
+// newGCM 创建 AES-GCM
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
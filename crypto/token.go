@@ -0,0 +1,97 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// TokenPrefix 令牌前缀，格式为 v2.<key_id>.<base64url(nonce||AES-GCM 加密的声明)>
+	TokenPrefix = "v2."
+	// TokenKeyEnv 未指定令牌密钥文件时读取令牌密钥的环境变量
+	TokenKeyEnv = "K8S_HELPER_TOKEN_KEYS"
+	// tokenClockSkew 校验签发时间时允许的时钟偏差
+	tokenClockSkew = time.Minute
+)
+
+var (
+	// ErrTokenInvalid 令牌格式错误、密钥未知或被篡改
+	ErrTokenInvalid = errors.New("invalid token")
+	// ErrTokenExpired 令牌已过期
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// TokenClaims 令牌声明
+type TokenClaims struct {
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	IssuedAt  int64  `json:"iat"` // 签发时间，Unix 秒
+	ExpiresAt int64  `json:"exp"` // 过期时间，Unix 秒
+	ID        string `json:"jti"` // 令牌唯一 ID
+}
+
+// IssueToken 使用当前主密钥签发令牌，有效期为 ttl
+func (k *KeyRing) IssueToken(name, role string, ttl time.Duration, now time.Time) (string, error) {
+	if name == "" {
+		return "", errors.New("token name is required")
+	}
+	if ttl <= 0 {
+		return "", errors.New("token ttl must be positive")
+	}
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(TokenClaims{
+		Name:      name,
+		Role:      role,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+		ID:        hex.EncodeToString(jti),
+	})
+	if err != nil {
+		return "", err
+	}
+	data, err := seal(k.keys[k.primary], claims, []byte(TokenPrefix+k.primary))
+	if err != nil {
+		return "", err
+	}
+	return TokenPrefix + k.primary + "." + base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// ParseToken 校验令牌的完整性和有效期，返回令牌声明
+func (k *KeyRing) ParseToken(token string, now time.Time) (*TokenClaims, error) {
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(token, TokenPrefix), ".")
+	if !strings.HasPrefix(token, TokenPrefix) || !ok {
+		return nil, fmt.Errorf("%w: malformed token", ErrTokenInvalid)
+	}
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %s", ErrTokenInvalid, id)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed token", ErrTokenInvalid)
+	}
+	plain, err := open(key, data, []byte(TokenPrefix+id))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenInvalid, err)
+	}
+	var claims TokenClaims
+	if err := json.Unmarshal(plain, &claims); err != nil || claims.Name == "" || claims.ID == "" {
+		return nil, fmt.Errorf("%w: malformed claims", ErrTokenInvalid)
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, fmt.Errorf("%w: jti=%s", ErrTokenExpired, claims.ID)
+	}
+	if time.Unix(claims.IssuedAt, 0).After(now.Add(tokenClockSkew)) {
+		return nil, fmt.Errorf("%w: issued in the future, jti=%s", ErrTokenInvalid, claims.ID)
+	}
+	return &claims, nil
+}
@@ -0,0 +1,85 @@
+package dao
+
+import (
+	"fmt"
+	"time"
+)
+
+// 审批状态
+const (
+	ApprovalPending  = "pending"  // 待审批
+	ApprovalRejected = "rejected" // 已拒绝
+	ApprovalApproved = "approved" // 已批准，执行中
+	ApprovalExecuted = "executed" // 已批准并执行成功
+	ApprovalFailed   = "failed"   // 已批准但执行失败
+)
+
+// Approval 变更类工具调用的审批记录，对应 approvals 表
+type Approval struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	Tool          string     `gorm:"size:128;not null" json:"tool"`
+	Arguments     string     `gorm:"type:text" json:"arguments"` // 工具参数 JSON
+	ClusterName   string     `gorm:"size:255;index" json:"cluster_name"`
+	Namespace     string     `gorm:"size:255" json:"namespace"`
+	Reason        string     `gorm:"size:255" json:"reason"` // 需要审批的原因
+	RequestedBy   string     `gorm:"size:255" json:"requested_by"`
+	RequesterRole string     `gorm:"size:64" json:"requester_role"`
+	SessionID     string     `gorm:"size:128" json:"session_id"` // 发起调用的 MCP 会话，执行后向其推送结果
+	Status        string     `gorm:"size:32;index;not null" json:"status"`
+	Approver      string     `gorm:"size:255" json:"approver,omitempty"`
+	Comment       string     `gorm:"type:text" json:"comment,omitempty"`
+	Result        string     `gorm:"type:text" json:"result,omitempty"` // 执行结果
+	CreatedAt     time.Time  `json:"created_at"`
+	DecidedAt     *time.Time `json:"decided_at,omitempty"`
+}
+
+// CreateApproval 新建待审批记录
+func CreateApproval(a *Approval) error {
+	a.Status = ApprovalPending
+	return GetDB().Create(a).Error
+}
+
+// GetApproval 按 ID 查询审批记录
+func GetApproval(id uint) (*Approval, error) {
+	var a Approval
+	if err := GetDB().First(&a, id).Error; err != nil {
+		return nil, fmt.Errorf("approval %d not found: %w", id, err)
+	}
+	return &a, nil
+}
+
+// ListApprovals 按状态查询审批记录，status 为空时查询全部，按创建时间倒序
+func ListApprovals(status string, limit int) ([]Approval, error) {
+	query := GetDB().Order("id DESC").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var approvals []Approval
+	err := query.Find(&approvals).Error
+	return approvals, err
+}
+
+// ClaimApproval 将待审批记录标记为 ApprovalApproved 或 ApprovalRejected
+// 仅 pending 状态可修改，保证同一记录只被处理一次
+func ClaimApproval(id uint, status, approver, comment string) (*Approval, error) {
+	now := time.Now()
+	result := GetDB().Model(&Approval{}).
+		Where("id = ? AND status = ?", id, ApprovalPending).
+		Updates(map[string]any{"status": status, "approver": approver, "comment": comment, "decided_at": now})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		if _, err := GetApproval(id); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("approval %d is not pending", id)
+	}
+	return GetApproval(id)
+}
+
+// SetApprovalResult 记录审批通过后的执行结果
+func SetApprovalResult(id uint, status, result string) error {
+	return GetDB().Model(&Approval{}).Where("id = ?", id).
+		Updates(map[string]any{"status": status, "result": result}).Error
+}
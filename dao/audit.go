@@ -0,0 +1,73 @@
+package dao
+
+import "time"
+
+// 审计结果
+const (
+	AuditSuccess = "success"
+	AuditError   = "error"
+)
+
+// AuditLog 工具调用审计记录，对应 audit_log 表
+type AuditLog struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+	SessionID   string    `gorm:"size:128" json:"session_id"`
+	UserID      string    `gorm:"size:255;index" json:"user_id"`
+	Role        string    `gorm:"size:64" json:"role"`
+	Approver    string    `gorm:"size:255" json:"approver,omitempty"` // 审批通过后执行时的审批人，UserID 为发起人
+	Tool        string    `gorm:"size:128;index" json:"tool"`
+	Params      string    `gorm:"type:text" json:"params"` // 已脱敏的参数 JSON
+	ClusterName string    `gorm:"size:255;index" json:"cluster_name"`
+	Namespace   string    `gorm:"size:255" json:"namespace"`
+	Outcome     string    `gorm:"size:32;index" json:"outcome"`
+	Error       string    `gorm:"type:text" json:"error,omitempty"`
+	DurationMs  int64     `json:"duration_ms"`
+}
+
+// TableName 指定表名为 audit_log
+func (AuditLog) TableName() string {
+	return "audit_log"
+}
+
+// AuditLogQuery 审计记录查询条件，字段为空时不过滤
+type AuditLogQuery struct {
+	UserID      string
+	Tool        string
+	ClusterName string
+	Namespace   string
+	Outcome     string
+	Since       time.Time
+	Until       time.Time
+	Limit       int
+}
+
+// CreateAuditLog 写入一条审计记录
+func CreateAuditLog(l *AuditLog) error {
+	return GetDB().Create(l).Error
+}
+
+// QueryAuditLogs 按条件查询审计记录，按时间倒序
+func QueryAuditLogs(q AuditLogQuery) ([]AuditLog, error) {
+	query := GetDB().Order("id DESC").Limit(q.Limit)
+	for column, value := range map[string]string{
+		"user_id":      q.UserID,
+		"tool":         q.Tool,
+		"cluster_name": q.ClusterName,
+		"namespace":    q.Namespace,
+		"outcome":      q.Outcome,
+	} {
+		if value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+	if !q.Since.IsZero() {
+		query = query.Where("created_at >= ?", q.Since)
+	}
+	if !q.Until.IsZero() {
+		query = query.Where("created_at < ?", q.Until)
+	}
+	var logs []AuditLog
+	err := query.Find(&logs).Error
+	return logs, err
+}
@@ -1,5 +1,67 @@
 package dao
 
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/relaxyabc/k8s-helper/crypto"
+	"gorm.io/gorm"
+	"k8s.io/klog/v2"
+)
+
+// ErrClusterNotFound 集群不存在或已删除
+var ErrClusterNotFound = errors.New("cluster not found")
+
+// kubeConfigKeys kube_config 信封加密主密钥，为 nil 时以明文存储
+var kubeConfigKeys *crypto.KeyRing
+
+// SetKubeConfigKeyRing 设置 kube_config 加密主密钥，启动时由 main 调用
+func SetKubeConfigKeyRing(k *crypto.KeyRing) {
+	kubeConfigKeys = k
+}
+
+// encryptKubeConfig 加密 kube_config，以集群名作为附加认证数据；未配置主密钥时原样返回
+func encryptKubeConfig(clusterName, kubeConfig string) (string, error) {
+	if kubeConfigKeys == nil || kubeConfig == "" {
+		return kubeConfig, nil
+	}
+	return kubeConfigKeys.Encrypt([]byte(kubeConfig), []byte(clusterName))
+}
+
+// decryptKubeConfig 解密 kube_config，未加密的存量明文原样返回
+func decryptKubeConfig(clusterName, kubeConfig string) (string, error) {
+	if !crypto.IsEncrypted(kubeConfig) {
+		return kubeConfig, nil
+	}
+	if kubeConfigKeys == nil {
+		return "", fmt.Errorf("kube_config of cluster %s is encrypted but no master key is configured", clusterName)
+	}
+	plain, err := kubeConfigKeys.Decrypt(kubeConfig, []byte(clusterName))
+	if err != nil {
+		return "", fmt.Errorf("decrypt kube_config of cluster %s: %w", clusterName, err)
+	}
+	return string(plain), nil
+}
+
+// Cluster clusters 表模型，删除为软删除（设置 deleted_at）
+type Cluster struct {
+	ClusterName           string         `gorm:"column:cluster_name;type:text;primaryKey" json:"cluster_name"`
+	IP                    string         `gorm:"column:ip;type:text" json:"ip"`
+	KubeConfig            string         `gorm:"column:kube_config;type:text" json:"-"`
+	InsecureSkipTLSVerify bool           `gorm:"column:insecure_skip_tls_verify;not null;default:false" json:"insecure_skip_tls_verify"`
+	CABundle              string         `gorm:"column:ca_bundle;type:text" json:"ca_bundle,omitempty"`
+	Protected             bool           `gorm:"column:protected;not null;default:false" json:"protected"`
+	CreatedAt             time.Time      `json:"created_at"`
+	UpdatedAt             time.Time      `json:"updated_at"`
+	DeletedAt             gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName 指定表名为 clusters
+func (Cluster) TableName() string {
+	return "clusters"
+}
+
 // ClusterInfo 表示集群信息
 // 包含集群名和 IP
 // 用于 clusters 表的查询结果映射
@@ -11,18 +73,11 @@ type ClusterInfo struct {
 // GetClusterInfos 查询所有集群名和IP
 // 返回值: ClusterInfo 切片和错误信息
 func GetClusterInfos() ([]ClusterInfo, error) {
-	var clusters []struct {
-		ClusterName string `gorm:"column:cluster_name"`
-		IP          string `gorm:"column:ip"`
-	}
-	err := GetDB().Table("clusters").Select("cluster_name, ip").Find(&clusters).Error
+	var result []ClusterInfo
+	err := GetDB().Model(&Cluster{}).Select("cluster_name, ip").Order("cluster_name").Scan(&result).Error
 	if err != nil {
 		return nil, err
 	}
-	var result []ClusterInfo
-	for _, c := range clusters {
-		result = append(result, ClusterInfo{ClusterName: c.ClusterName, IP: c.IP})
-	}
 	return result, nil
 }
 
@@ -30,10 +85,155 @@ func GetClusterInfos() ([]ClusterInfo, error) {
 // 参数 clusterName: 集群名
 // 返回值: kube_config 字符串和错误信息
 func GetKubeConfig(clusterName string) (string, error) {
-	var kubeconfig string
-	err := GetDB().Table("clusters").Select("kube_config").Where("cluster_name = ?", clusterName).Scan(&kubeconfig).Error
+	c, err := GetCluster(clusterName)
 	if err != nil {
 		return "", err
 	}
-	return kubeconfig, nil
+	return c.KubeConfig, nil
+}
+
+// ClusterConnection 连接集群所需的信息
+// InsecureSkipTLSVerify 为 true 时跳过 TLS 校验；CABundle 为额外信任的 PEM 格式 CA 证书
+type ClusterConnection struct {
+	KubeConfig            string
+	InsecureSkipTLSVerify bool
+	CABundle              string
+}
+
+// GetClusterConnection 获取指定集群的 kube_config 及 TLS 设置
+// 参数 clusterName: 集群名
+// 返回值: 连接信息和错误信息，集群不存在时返回错误
+func GetClusterConnection(clusterName string) (*ClusterConnection, error) {
+	c, err := GetCluster(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	return &ClusterConnection{KubeConfig: c.KubeConfig, InsecureSkipTLSVerify: c.InsecureSkipTLSVerify, CABundle: c.CABundle}, nil
+}
+
+// IsClusterProtected 判断集群是否标记为受保护，受保护集群的变更操作需要审批
+func IsClusterProtected(clusterName string) (bool, error) {
+	c, err := GetCluster(clusterName)
+	if err != nil {
+		return false, err
+	}
+	return c.Protected, nil
+}
+
+// GetCluster 查询单个集群（kube_config 已解密），不存在或已删除时返回 ErrClusterNotFound
+func GetCluster(clusterName string) (*Cluster, error) {
+	var c Cluster
+	err := GetDB().Where("cluster_name = ?", clusterName).Take(&c).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrClusterNotFound, clusterName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.KubeConfig, err = decryptKubeConfig(c.ClusterName, c.KubeConfig); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ListClusters 查询所有未删除的集群，不含 kube_config
+func ListClusters() ([]Cluster, error) {
+	var clusters []Cluster
+	err := GetDB().Omit("kube_config").Order("cluster_name").Find(&clusters).Error
+	return clusters, err
+}
+
+// CreateCluster 新增集群，同名集群已存在时返回错误，同名集群已软删除时覆盖并恢复
+func CreateCluster(c *Cluster) error {
+	row := *c
+	var err error
+	if row.KubeConfig, err = encryptKubeConfig(c.ClusterName, c.KubeConfig); err != nil {
+		return err
+	}
+	err = GetDB().Transaction(func(tx *gorm.DB) error {
+		var existing Cluster
+		err := tx.Unscoped().Where("cluster_name = ?", row.ClusterName).Take(&existing).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return tx.Create(&row).Error
+		case err != nil:
+			return err
+		case !existing.DeletedAt.Valid:
+			return fmt.Errorf("cluster %s already exists", row.ClusterName)
+		}
+		row.CreatedAt = time.Now()
+		row.DeletedAt = gorm.DeletedAt{}
+		return tx.Unscoped().Save(&row).Error
+	})
+	c.CreatedAt, c.UpdatedAt = row.CreatedAt, row.UpdatedAt
+	return err
+}
+
+// UpdateCluster 更新集群的全部字段
+func UpdateCluster(c *Cluster) error {
+	row := *c
+	var err error
+	if row.KubeConfig, err = encryptKubeConfig(c.ClusterName, c.KubeConfig); err != nil {
+		return err
+	}
+	result := GetDB().Model(&row).Select("*").Omit("cluster_name", "created_at", "deleted_at").Updates(&row)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrClusterNotFound, c.ClusterName)
+	}
+	c.UpdatedAt = row.UpdatedAt
+	return nil
+}
+
+// ReencryptKubeConfigs 用当前主密钥重新加密所有集群（含已删除）的 kube_config，
+// 用于存量明文迁移和主密钥轮换，返回重新加密的记录数
+func ReencryptKubeConfigs() (int, error) {
+	if kubeConfigKeys == nil {
+		return 0, errors.New("no master key configured")
+	}
+	count := 0
+	err := GetDB().Transaction(func(tx *gorm.DB) error {
+		var rows []Cluster
+		if err := tx.Unscoped().Select("cluster_name", "kube_config").Find(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if row.KubeConfig == "" || !kubeConfigKeys.NeedsRotation(row.KubeConfig) {
+				continue
+			}
+			plain, err := decryptKubeConfig(row.ClusterName, row.KubeConfig)
+			if err != nil {
+				return err
+			}
+			encrypted, err := encryptKubeConfig(row.ClusterName, plain)
+			if err != nil {
+				return err
+			}
+			err = tx.Unscoped().Model(&Cluster{}).Where("cluster_name = ?", row.ClusterName).UpdateColumn("kube_config", encrypted).Error
+			if err != nil {
+				return err
+			}
+			klog.Infof("[CLUSTER] re-encrypted kube_config of cluster %s with master key %s", row.ClusterName, kubeConfigKeys.Primary())
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// DeleteCluster 软删除集群
+func DeleteCluster(clusterName string) error {
+	result := GetDB().Where("cluster_name = ?", clusterName).Delete(&Cluster{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrClusterNotFound, clusterName)
+	}
+	return nil
 }
@@ -17,8 +17,21 @@ func InitDBByArgs(host, port, dbname, user, password string) {
 	if err != nil {
 		klog.Fatalf("数据库连接失败: %v", err)
 	}
-	dbConn = dbInstance
 	fmt.Println("数据库连接成功")
+	if err := InitDB(dbInstance); err != nil {
+		klog.Fatalf("数据库表结构迁移失败: %v", err)
+	}
+}
+
+// InitDB 使用已建立的连接并迁移表结构，测试中可传入 sqlite 连接
+func InitDB(db *gorm.DB) error {
+	dbConn = db
+	return autoMigrate()
+}
+
+// autoMigrate 创建表或为已有表补充缺失字段
+func autoMigrate() error {
+	return dbConn.AutoMigrate(&Cluster{}, &Approval{}, &AuditLog{}, &Session{})
 }
 
 func initDB(host, port, dbname, user, password string) (*gorm.DB, error) {
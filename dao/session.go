@@ -0,0 +1,78 @@
+package dao
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Session MCP 会话记录，对应 mcp_sessions 表，用于服务重启和多副本间共享会话
+type Session struct {
+	ID         string `gorm:"primaryKey;size:128"`
+	UserID     string `gorm:"size:255;index"`
+	Role       string `gorm:"size:64"`
+	Transport  string `gorm:"size:16"`
+	LastTool   string `gorm:"size:128"`
+	Data       string `gorm:"type:text"` // 会话数据 JSON
+	CreatedAt  time.Time
+	LastAccess time.Time
+	ExpiresAt  time.Time `gorm:"index"`
+	// TokenExpiresAt 创建会话的 mcpId 令牌过期时间，零值表示不限制
+	TokenExpiresAt time.Time
+}
+
+// TableName 指定表名为 mcp_sessions
+func (Session) TableName() string {
+	return "mcp_sessions"
+}
+
+// GetSession 按 ID 查询会话，不存在时返回 nil
+func GetSession(id string) (*Session, error) {
+	var s Session
+	err := GetDB().Where("id = ?", id).Take(&s).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SaveSession 新增或覆盖会话
+func SaveSession(s *Session) error {
+	return GetDB().Save(s).Error
+}
+
+// DeleteSession 删除会话
+func DeleteSession(id string) error {
+	return GetDB().Where("id = ?", id).Delete(&Session{}).Error
+}
+
+// ListSessions 查询会话，userID 为空时查询全部，按创建时间排序
+func ListSessions(userID string) ([]Session, error) {
+	query := GetDB().Order("created_at")
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	var sessions []Session
+	err := query.Find(&sessions).Error
+	return sessions, err
+}
+
+// DeleteExpiredSessions 删除 now 之前过期的会话，返回被删除的会话 ID
+func DeleteExpiredSessions(now time.Time) ([]string, error) {
+	var deleted []Session
+	err := GetDB().Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+		Where("expires_at < ?", now).Delete(&deleted).Error
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(deleted))
+	for i, s := range deleted {
+		ids[i] = s.ID
+	}
+	return ids, nil
+}
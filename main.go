@@ -2,10 +2,12 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/mark3labs/mcp-go/server"
+	"github.com/relaxyabc/k8s-helper/crypto"
 	"github.com/relaxyabc/k8s-helper/dao"
 	"github.com/relaxyabc/k8s-helper/mcp"
 	"k8s.io/klog/v2"
@@ -15,6 +17,16 @@ func main() {
 	var transport string
 	var dbhost, dbport, dbname, dbuser, dbpass, proxy string
 	var aesKeyFlag string
+	var policyFile string
+	var httpStyleTools bool
+	var masterKeyFile string
+	var reencrypt bool
+	var tokenKeyFile, issueTokenUser, issueTokenRole string
+	var tokenTTL time.Duration
+	var allowLegacyMcpID bool
+	var sessionStoreKind string
+	var stdioUser, stdioRole string
+	var sessionPolicy mcp.SessionPolicy
 	var addr string
 	flag.StringVar(&transport, "t", "", "Transport type (stdio, http, or sse)")
 	flag.StringVar(&transport, "transport", "", "Transport type (stdio, http, or sse)")
@@ -25,39 +37,109 @@ func main() {
 	flag.StringVar(&dbuser, "dbuser", "postgres", "数据库用户名")
 	flag.StringVar(&dbpass, "dbpass", "", "数据库密码")
 	flag.StringVar(&proxy, "proxy", "", "代理地址")
-	flag.StringVar(&aesKeyFlag, "aeskey", "k8s-mcp-client", "AES加密key")
+	flag.StringVar(&aesKeyFlag, "aeskey", "k8s-mcp-client", "旧版 mcpId 的 AES 加密 key（已废弃，仅 -allow-legacy-mcpid 时使用）")
+	flag.StringVar(&policyFile, "policy", "", "RBAC 策略文件路径（YAML/JSON），为空时使用内置策略")
+	flag.BoolVar(&httpStyleTools, "http-style-tools", false, "以旧版 HTTP 风格（method/url/body）暴露工具，兼容存量客户端")
+	flag.StringVar(&masterKeyFile, "master-key-file", "", "kubeconfig 加密主密钥文件，为空时读取环境变量 "+crypto.MasterKeyEnv)
+	flag.BoolVar(&reencrypt, "reencrypt-kubeconfig", false, "用当前主密钥重新加密 clusters 表中所有 kubeconfig（迁移明文或轮换密钥）后退出")
+	flag.StringVar(&tokenKeyFile, "token-key-file", "", "mcpId 令牌密钥文件，为空时读取环境变量 "+crypto.TokenKeyEnv)
+	flag.BoolVar(&allowLegacyMcpID, "allow-legacy-mcpid", false, "兼容旧版 AES-CBC 格式的 mcpId（已废弃，无完整性校验且不过期）")
+	flag.StringVar(&issueTokenUser, "issue-token-user", "", "为指定用户签发 mcpId 令牌并输出后退出")
+	flag.StringVar(&issueTokenRole, "issue-token-role", "", "签发令牌的角色")
+	flag.DurationVar(&tokenTTL, "token-ttl", 30*24*time.Hour, "签发令牌的有效期")
+	flag.StringVar(&sessionStoreKind, "session-store", mcp.SessionStoreMemory, "http/sse 会话存储（memory 或 postgres），postgres 可在重启和多副本间保留会话")
+	flag.StringVar(&stdioUser, "stdio-user", "stdio", "stdio 模式下调用方的用户 ID")
+	flag.StringVar(&stdioRole, "stdio-role", "user", "stdio 模式下调用方的角色，默认为最小权限的 user")
+	flag.StringVar(&sessionPolicy.Mode, "session-policy", mcp.SessionPolicyShare, "同一用户多个连接的会话策略：share 共用一个会话，limit 超出上限时拒绝新会话，evict 超出上限时淘汰最早的会话")
+	flag.IntVar(&sessionPolicy.MaxPerUser, "max-sessions-per-user", 3, "limit/evict 策略下每个用户的会话数上限")
 	flag.Parse()
 
 	if transport == "" {
 		transport = "stdio"
 	}
 
+	tokenKeys, err := crypto.LoadKeyRing(tokenKeyFile, crypto.TokenKeyEnv)
+	if err != nil {
+		klog.Fatalf("加载令牌密钥失败: %v", err)
+	}
+	if issueTokenUser != "" {
+		if tokenKeys == nil {
+			klog.Fatalf("签发令牌需要配置 -token-key-file 或环境变量 %s", crypto.TokenKeyEnv)
+		}
+		token, err := tokenKeys.IssueToken(issueTokenUser, issueTokenRole, tokenTTL, time.Now())
+		if err != nil {
+			klog.Fatalf("签发令牌失败: %v", err)
+		}
+		fmt.Println(token)
+		return
+	}
+
 	dao.InitDBByArgs(dbhost, dbport, dbname, dbuser, dbpass)
+	keys, err := crypto.LoadKeyRing(masterKeyFile, crypto.MasterKeyEnv)
+	if err != nil {
+		klog.Fatalf("加载主密钥失败: %v", err)
+	}
+	if keys == nil {
+		klog.Warning("未配置主密钥，kubeconfig 将以明文存储")
+	}
+	dao.SetKubeConfigKeyRing(keys)
+	if reencrypt {
+		n, err := dao.ReencryptKubeConfigs()
+		if err != nil {
+			klog.Fatalf("重新加密 kubeconfig 失败: %v", err)
+		}
+		klog.Infof("已重新加密 %d 个集群的 kubeconfig", n)
+		return
+	}
 	mcp.Init(proxy, aesKeyFlag, transport)
+	mcp.HTTPStyleTools = httpStyleTools
+	mcp.TokenKeys = tokenKeys
+	mcp.AllowLegacyMcpID = allowLegacyMcpID
+	if tokenKeys == nil && !allowLegacyMcpID {
+		klog.Warning("未配置令牌密钥，mcpId 认证不可用")
+	}
+	if policyFile != "" {
+		if err := mcp.LoadRBACPolicyFile(policyFile); err != nil {
+			klog.Fatalf("加载 RBAC 策略失败: %v", err)
+		}
+	}
+
+	sessionStore, err := mcp.NewSessionStore(sessionStoreKind)
+	if err != nil {
+		klog.Fatalf("创建会话存储失败: %v", err)
+	}
+	if err := sessionPolicy.Validate(); err != nil {
+		klog.Fatalf("会话策略配置错误: %v", err)
+	}
 
 	switch transport {
 	case "stdio":
 		s := mcp.NewMCPServer()
-		klog.Info("[MCP] Starting in stdio mode, waiting for client to connect...")
+		s.SetDefaultIdentity(mcp.Identity{UserID: stdioUser, Role: stdioRole})
+		klog.Infof("[MCP] Starting in stdio mode as user=%s, role=%s, waiting for client to connect...", stdioUser, stdioRole)
 		if err := s.ServeStdio(); err != nil {
 			klog.Fatalf("Server error: %v", err)
 		}
 	case "http":
 		s := mcp.NewMCPServer()
-		httpSessionMgr := mcp.NewHTTPSessionManager(30*time.Minute, s)
+		httpSessionMgr := mcp.NewHTTPSessionManager(sessionStore, 30*time.Minute, s)
+		httpSessionMgr.SetPolicy(sessionPolicy)
 		klog.Info("[MCP] Starting in HTTP mode, using MCPServer as handler...")
 		mux := http.NewServeMux()
 		mux.HandleFunc("/logout", func(w http.ResponseWriter, r *http.Request) {
 			c, err := r.Cookie("SESSIONID")
 			if err == nil {
-				httpSessionMgr.DeleteSession(c.Value, s)
+				httpSessionMgr.DeleteSession(c.Value)
 				http.SetCookie(w, &http.Cookie{Name: "SESSIONID", Value: "", Path: "/", MaxAge: -1})
 			}
 			w.Write([]byte("logout success"))
 		})
 		// 自定义 /mcp handler，显式处理 sid、用户、会话注册
 		mux.Handle("/mcp", s.ServeHTTP())
-		handler := mcp.SessionMiddleware(httpSessionMgr, s, mux)
+		s.RegisterApprovalHandlers(mux)
+		s.RegisterClusterHandlers(mux)
+		s.RegisterSessionHandlers(mux)
+		handler := mcp.SessionMiddleware(httpSessionMgr, mux)
 		listenAddr := ":" + addr
 		klog.Infof("[MCP] HTTP server listening on %s (via MCPServer)", listenAddr)
 		if err := http.ListenAndServe(listenAddr, handler); err != nil {
@@ -67,7 +149,8 @@ func main() {
 
 		// Create the MCP server with the hooks.
 		s := mcp.NewMCPServer()
-		httpSessionMgr := mcp.NewHTTPSessionManager(30*time.Minute, s)
+		httpSessionMgr := mcp.NewHTTPSessionManager(sessionStore, 30*time.Minute, s)
+		httpSessionMgr.SetPolicy(sessionPolicy)
 
 		listenAddr := ":" + addr
 		klog.Infof("[MCP] Starting SSE server on %s", listenAddr)
@@ -117,8 +200,11 @@ func main() {
 
 		// 注册 /mcp handler，显式处理 sid、用户、会话注册
 		mux.Handle("/mcp", s.ServeHTTP())
+		s.RegisterApprovalHandlers(mux)
+		s.RegisterClusterHandlers(mux)
+		s.RegisterSessionHandlers(mux)
 
-		handler := mcp.SessionMiddleware(httpSessionMgr, s, mux)
+		handler := mcp.SessionMiddleware(httpSessionMgr, mux)
 		klog.Infof("SSE server listening on %s", listenAddr)
 		if err := http.ListenAndServe(listenAddr, handler); err != nil {
 			klog.Fatalf("Server error: %v", err)
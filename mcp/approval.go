@@ -0,0 +1,346 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/relaxyabc/k8s-helper/common"
+	"github.com/relaxyabc/k8s-helper/dao"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// adminRole 变更操作无需审批的角色（受保护集群除外）
+	adminRole = "admin"
+	// approvalNotificationMethod 审批结果通知的 method
+	approvalNotificationMethod = "notifications/approval"
+	// defaultApprovalListLimit 审批列表默认返回条数
+	defaultApprovalListLimit = 50
+	// maxApprovalListLimit 审批列表最大返回条数
+	maxApprovalListLimit = 500
+)
+
+// approvalListLimit 规范化审批列表条数，非正数或超过上限时取上限
+func approvalListLimit(limit int) int {
+	if limit <= 0 || limit > maxApprovalListLimit {
+		return maxApprovalListLimit
+	}
+	return limit
+}
+
+// approvalReason 判断变更类工具调用是否需要审批，返回原因，无需审批时返回空串
+func approvalReason(role, clusterName string) string {
+	if role != adminRole {
+		return fmt.Sprintf("角色 %s 的变更操作需要审批", role)
+	}
+	protected, err := dao.IsClusterProtected(clusterName)
+	if err != nil {
+		klog.Errorf("[APPROVAL] check protected cluster %s failed: %v", clusterName, err)
+		return "无法确认集群是否受保护，按受保护处理"
+	}
+	if protected {
+		return fmt.Sprintf("集群 %s 受保护，变更操作需要审批", clusterName)
+	}
+	return ""
+}
+
+// createApproval 为变更类工具调用创建待审批记录，sid 为发起调用的会话
+func (s *MCPServer) createApproval(sid string, spec toolSpec, args toolArgs, reason string) (*dao.Approval, error) {
+	argsJSON, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("序列化参数失败: %w", err)
+	}
+	caller := s.identity(sid)
+	a := &dao.Approval{
+		Tool:          spec.Name,
+		Arguments:     string(argsJSON),
+		ClusterName:   args.String("cluster_name"),
+		Namespace:     args.String("namespace"),
+		Reason:        reason,
+		RequestedBy:   caller.UserID,
+		RequesterRole: caller.Role,
+		SessionID:     sid,
+	}
+	if err := dao.CreateApproval(a); err != nil {
+		return nil, fmt.Errorf("创建审批记录失败: %w", err)
+	}
+	klog.Infof("[APPROVAL] created id=%d, tool=%s, user=%s, role=%s, reason=%s", a.ID, a.Tool, caller.UserID, caller.Role, reason)
+	return a, nil
+}
+
+// approvalPending 提交审批后返回给调用方的内容
+func approvalPending(a *dao.Approval) map[string]any {
+	return map[string]any{
+		"approval_id":         a.ID,
+		"status":              a.Status,
+		"reason":              a.Reason,
+		"notification_method": approvalNotificationMethod,
+		"message":             "已提交审批，审批通过后执行，结果将推送到当前会话；可先用 dry_run 预览变更",
+	}
+}
+
+// requestApproval 为变更类工具调用创建待审批记录，代替直接执行
+func (s *MCPServer) requestApproval(ctx context.Context, spec toolSpec, args toolArgs, reason string) (*mcp.CallToolResult, error) {
+	a, err := s.createApproval(sessionIDFromContext(ctx), spec, args, reason)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return jsonResult(approvalPending(a))
+}
+
+// redactApproval 将审批记录中的敏感参数替换为 ***，用于返回给调用方
+func redactApproval(a *dao.Approval) {
+	var args map[string]any
+	if err := json.Unmarshal([]byte(a.Arguments), &args); err != nil {
+		return
+	}
+	if redacted, err := json.Marshal(RedactParams(args)); err == nil {
+		a.Arguments = string(redacted)
+	}
+}
+
+// decideApproval 批准或拒绝审批，审批人需有权访问审批记录的集群和命名空间，批准后执行原工具调用，并通知发起调用的会话
+func (s *MCPServer) decideApproval(id uint, approve bool, approver Identity, comment string) (*dao.Approval, error) {
+	a, err := dao.GetApproval(id)
+	if err != nil {
+		return nil, err
+	}
+	if approver.UserID != "" && approver.UserID == a.RequestedBy {
+		return nil, fmt.Errorf("不能审批自己发起的请求")
+	}
+	if err := checkScope(approver.Role, a.ClusterName, a.Namespace); err != nil {
+		klog.Warningf("[AUTHZ] denied: approval id=%d, approver=%s, role=%s, cluster=%s, namespace=%s", a.ID, approver.UserID, approver.Role, a.ClusterName, a.Namespace)
+		return nil, err
+	}
+	spec, ok := s.mutatingTools[a.Tool]
+	if approve && !ok {
+		return nil, fmt.Errorf("工具 %s 不存在", a.Tool)
+	}
+	status := dao.ApprovalRejected
+	if approve {
+		status = dao.ApprovalApproved
+	}
+	a, err = dao.ClaimApproval(id, status, approver.UserID, comment)
+	if err != nil {
+		return nil, err
+	}
+	if approve {
+		a.Status, a.Result = s.executeApproval(spec, a)
+		if err := dao.SetApprovalResult(a.ID, a.Status, a.Result); err != nil {
+			klog.Errorf("[APPROVAL] save result of id=%d failed: %v", a.ID, err)
+		}
+	}
+	klog.Infof("[APPROVAL] decided id=%d, tool=%s, status=%s, approver=%s", a.ID, a.Tool, a.Status, approver.UserID)
+	s.notifyApproval(a)
+	return a, nil
+}
+
+// executeApproval 以审批记录中保存的参数执行工具，返回最终状态和结果文本。
+// 执行前按当前策略重新校验发起人的权限，panic 按执行失败处理，并写入同时记录发起人和审批人的审计日志
+func (s *MCPServer) executeApproval(spec toolSpec, a *dao.Approval) (status, text string) {
+	start := time.Now()
+	args := toolArgs{}
+	defer func() {
+		if r := recover(); r != nil {
+			klog.Errorf("[APPROVAL] execute id=%d panic: %v\n%s", a.ID, r, debug.Stack())
+			status, text = dao.ApprovalFailed, fmt.Sprintf("panic: %v", r)
+		}
+		paramsJSON, _ := json.Marshal(RedactParams(args))
+		entry := &dao.AuditLog{
+			CreatedAt:   start,
+			SessionID:   a.SessionID,
+			UserID:      a.RequestedBy,
+			Role:        a.RequesterRole,
+			Approver:    a.Approver,
+			Tool:        a.Tool,
+			Params:      string(paramsJSON),
+			ClusterName: a.ClusterName,
+			Namespace:   a.Namespace,
+			Outcome:     dao.AuditSuccess,
+			DurationMs:  time.Since(start).Milliseconds(),
+		}
+		if status != dao.ApprovalExecuted {
+			entry.Outcome, entry.Error = dao.AuditError, text
+		}
+		writeAuditLog(entry)
+	}()
+	if err := json.Unmarshal([]byte(a.Arguments), &args); err != nil {
+		return dao.ApprovalFailed, "解析参数失败: " + err.Error()
+	}
+	if !IsToolAllowed(a.RequesterRole, a.Tool) {
+		return dao.ApprovalFailed, fmt.Sprintf("发起人角色 %s 已无权调用工具 %s", a.RequesterRole, a.Tool)
+	}
+	if err := checkScope(a.RequesterRole, a.ClusterName, a.Namespace); err != nil {
+		return dao.ApprovalFailed, err.Error()
+	}
+	if err := spec.validate(args); err != nil {
+		return dao.ApprovalFailed, err.Error()
+	}
+	result, err := spec.Handler(context.Background(), args)
+	if err != nil {
+		return dao.ApprovalFailed, err.Error()
+	}
+	if result.IsError {
+		return dao.ApprovalFailed, resultText(result)
+	}
+	return dao.ApprovalExecuted, resultText(result)
+}
+
+// notifyApproval 向发起调用的会话推送审批结果，会话没有可用的通知流时仅记录日志
+func (s *MCPServer) notifyApproval(a *dao.Approval) {
+	if a.SessionID == "" {
+		return
+	}
+	sent := s.notifySession(a.SessionID, approvalNotificationMethod, map[string]any{
+		"approval_id": a.ID,
+		"tool":        a.Tool,
+		"status":      a.Status,
+		"approver":    a.Approver,
+		"comment":     a.Comment,
+		"result":      a.Result,
+	})
+	if sent == 0 {
+		klog.Warningf("[APPROVAL] no notification stream for session %s, result of id=%d not pushed", a.SessionID, a.ID)
+	}
+}
+
+// registerApprovalTools 注册审批相关工具，内置策略仅 admin 可用
+func (s *MCPServer) registerApprovalTools() {
+	statusEnum := []string{dao.ApprovalPending, dao.ApprovalRejected, dao.ApprovalApproved, dao.ApprovalExecuted, dao.ApprovalFailed}
+	// list_approvals
+	s.registerTool(toolSpec{
+		Name:        "list_approvals",
+		Description: "List approval requests created by mutating tool calls, latest first",
+		Method:      "GET",
+		Path:        "/approvals",
+		Params: []toolParam{
+			{Name: "status", Type: paramString, Description: "按状态过滤，为空时查询全部", Enum: statusEnum},
+			{Name: "limit", Type: paramNumber, Description: fmt.Sprintf("返回条数上限，默认 %d，最大 %d", defaultApprovalListLimit, maxApprovalListLimit)},
+		},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			approvals, err := dao.ListApprovals(args.String("status"), approvalListLimit(args.Int("limit", defaultApprovalListLimit)))
+			if err != nil {
+				return mcp.NewToolResultError("查询审批记录失败: " + err.Error()), nil
+			}
+			for i := range approvals {
+				redactApproval(&approvals[i])
+			}
+			return jsonResult(approvals)
+		},
+	})
+	for _, approve := range []bool{true, false} {
+		name, path, desc := "approve_request", "/approve_request", "Approve a pending request, the original tool call runs and its result is pushed to the requesting session"
+		if !approve {
+			name, path, desc = "reject_request", "/reject_request", "Reject a pending request, the requesting session is notified"
+		}
+		s.registerTool(toolSpec{
+			Name:        name,
+			Description: desc,
+			Method:      "POST",
+			Path:        path,
+			Params: []toolParam{
+				{Name: "id", Type: paramNumber, Required: true, Description: "审批记录 ID，取自 list_approvals"},
+				{Name: "comment", Type: paramString, Description: "审批意见"},
+			},
+			Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+				approver := s.identity(sessionIDFromContext(ctx))
+				a, err := s.decideApproval(uint(args.Int("id", 0)), approve, approver, args.String("comment"))
+				if err != nil {
+					return mcp.NewToolResultError("审批失败: " + err.Error()), nil
+				}
+				redactApproval(a)
+				return jsonResult(a)
+			},
+		})
+	}
+}
+
+// RegisterApprovalHandlers 注册审批管理 HTTP 接口，调用方角色需有对应工具的权限：
+// GET /admin/approvals?status=pending 查询审批记录（list_approvals）
+// POST /admin/approvals/{id}/approve 批准（approve_request）
+// POST /admin/approvals/{id}/reject 拒绝（reject_request）
+func (s *MCPServer) RegisterApprovalHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("GET /admin/approvals", func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := s.authorizeHTTP(w, r, "list_approvals"); !ok {
+			return
+		}
+		limit := defaultApprovalListLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "limit 必须为整数"})
+				return
+			}
+			limit = approvalListLimit(n)
+		}
+		approvals, err := dao.ListApprovals(r.URL.Query().Get("status"), limit)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		for i := range approvals {
+			redactApproval(&approvals[i])
+		}
+		writeJSON(w, http.StatusOK, approvals)
+	})
+	for _, approve := range []bool{true, false} {
+		pattern, toolName := "POST /admin/approvals/{id}/approve", "approve_request"
+		if !approve {
+			pattern, toolName = "POST /admin/approvals/{id}/reject", "reject_request"
+		}
+		mux.HandleFunc(pattern, s.auditHTTP(toolName, func(w http.ResponseWriter, r *http.Request) {
+			userID, role, ok := s.authorizeHTTP(w, r, toolName)
+			if !ok {
+				return
+			}
+			id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid approval id"})
+				return
+			}
+			a, err := s.decideApproval(uint(id), approve, Identity{UserID: userID, Role: role}, r.FormValue("comment"))
+			var denied scopeError
+			switch {
+			case errors.As(err, &denied):
+				writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+				return
+			case err != nil:
+				writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+				return
+			}
+			setAuditScope(r, a.ClusterName, a.Namespace)
+			redactApproval(a)
+			writeJSON(w, http.StatusOK, a)
+		}))
+	}
+}
+
+// authorizeHTTP 校验管理接口调用方的角色是否有 toolName 的权限，返回调用方用户 ID 和角色
+func (s *MCPServer) authorizeHTTP(w http.ResponseWriter, r *http.Request, toolName string) (string, string, bool) {
+	sid, _ := r.Context().Value(common.ContextKeyMcpSession).(string)
+	caller := s.identity(sid)
+	userID, role := caller.UserID, caller.Role
+	if !IsToolAllowed(role, toolName) {
+		klog.Warningf("[AUTHZ] denied: sid=%s, user=%s, role=%s, http=%s %s", sid, userID, role, r.Method, r.URL.Path)
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": fmt.Sprintf("角色 %s 无权访问", role)})
+		return "", "", false
+	}
+	return userID, role, true
+}
+
+// writeJSON 以 JSON 格式写出 HTTP 响应
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		klog.Errorf("[HTTP] write response failed: %v", err)
+	}
+}
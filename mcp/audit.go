@@ -0,0 +1,306 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/relaxyabc/k8s-helper/common"
+	"github.com/relaxyabc/k8s-helper/dao"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// redactedValue 脱敏后的参数值
+	redactedValue = "***"
+	// defaultAuditQueryLimit query_audit_log 默认返回条数
+	defaultAuditQueryLimit = 100
+	// maxAuditQueryLimit query_audit_log 最大返回条数
+	maxAuditQueryLimit = 1000
+)
+
+// sensitiveKeys 参数名包含这些片段（不区分大小写）时视为敏感信息
+var sensitiveKeys = []string{"password", "passwd", "secret", "token", "credential", "kubeconfig", "kube_config", "api_key", "apikey", "private_key", "mcpid"}
+
+// isSensitiveKey 判断参数名是否为敏感信息
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactParams 返回脱敏后的参数副本，敏感字段（含嵌套对象和 HTTP 风格 url 的 query 参数）替换为 ***
+func RedactParams(params map[string]any) map[string]any {
+	redacted := make(map[string]any, len(params))
+	for k, v := range params {
+		redacted[k] = redactValue(k, v)
+	}
+	return redacted
+}
+
+// redactValue 按参数名脱敏单个值
+func redactValue(key string, v any) any {
+	if isSensitiveKey(key) {
+		return redactedValue
+	}
+	switch val := v.(type) {
+	case map[string]any:
+		return RedactParams(val)
+	case []any:
+		items := make([]any, len(val))
+		for i, item := range val {
+			items[i] = redactValue("", item)
+		}
+		return items
+	case string:
+		if key == "url" {
+			return redactURL(val)
+		}
+		if key == "body" {
+			var body map[string]any
+			if json.Unmarshal([]byte(val), &body) == nil {
+				data, _ := json.Marshal(RedactParams(body))
+				return string(data)
+			}
+		}
+	}
+	return v
+}
+
+// redactURL 脱敏 url 中的敏感 query 参数
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.RawQuery == "" {
+		return raw
+	}
+	query := u.Query()
+	for k := range query {
+		if isSensitiveKey(k) {
+			query.Set(k, redactedValue)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// auditScope 获取调用涉及的集群和命名空间，兼容 HTTP 风格参数
+func auditScope(params map[string]any) (string, string) {
+	args := toolArgs(params)
+	clusterName, namespace := args.String("cluster_name"), args.String("namespace")
+	if raw := args.String("url"); raw != "" {
+		if u, err := url.Parse(raw); err == nil {
+			query := u.Query()
+			if clusterName == "" {
+				clusterName = query.Get("cluster_name")
+			}
+			if namespace == "" {
+				namespace = query.Get("namespace")
+			}
+		}
+	}
+	return clusterName, namespace
+}
+
+// auditToolCall 审计中间件，记录每次工具调用（含被拒绝的调用）的调用方、参数、结果和耗时
+func (s *MCPServer) auditToolCall(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		start := time.Now()
+		result, err := next(ctx, request)
+
+		sid := sessionIDFromContext(ctx)
+		caller := s.identity(sid)
+		userID, role := caller.UserID, caller.Role
+		params := request.GetArguments()
+		paramsJSON, _ := json.Marshal(RedactParams(params))
+		clusterName, namespace := auditScope(params)
+		// 省略的集群和命名空间按会话上下文补全，与实际执行时一致；HTTP 风格的参数在 url 中，不补全
+		if spec, ok := s.contextTools[request.Params.Name]; ok && !HTTPStyleTools {
+			args := toolArgs{}
+			for k, v := range params {
+				args[k] = v
+			}
+			spec.applyContext(args, currentContext(ctx))
+			clusterName, namespace = auditScope(args)
+		}
+		entry := &dao.AuditLog{
+			CreatedAt:   start,
+			SessionID:   sid,
+			UserID:      userID,
+			Role:        role,
+			Tool:        request.Params.Name,
+			Params:      string(paramsJSON),
+			ClusterName: clusterName,
+			Namespace:   namespace,
+			Outcome:     dao.AuditSuccess,
+			DurationMs:  time.Since(start).Milliseconds(),
+		}
+		switch {
+		case err != nil:
+			entry.Outcome, entry.Error = dao.AuditError, err.Error()
+		case result != nil && result.IsError:
+			entry.Outcome, entry.Error = dao.AuditError, resultText(result)
+		}
+		writeAuditLog(entry)
+		return result, err
+	}
+}
+
+// auditEntryKey 管理接口请求上下文中审计记录的 key
+type auditEntryKey struct{}
+
+// auditResponseWriter 记录管理接口的响应状态码和错误响应内容
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *auditResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.status >= http.StatusBadRequest {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// auditHTTP 审计中间件，记录管理接口的每次调用（含被拒绝的调用），tool 为接口对应的工具名
+func (s *MCPServer) auditHTTP(tool string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sid, _ := r.Context().Value(common.ContextKeyMcpSession).(string)
+		caller := s.identity(sid)
+		paramsJSON, _ := json.Marshal(RedactParams(map[string]any{"method": r.Method, "url": r.URL.RequestURI()}))
+		entry := &dao.AuditLog{
+			CreatedAt: start,
+			SessionID: sid,
+			UserID:    caller.UserID,
+			Role:      caller.Role,
+			Tool:      tool,
+			Params:    string(paramsJSON),
+			Outcome:   dao.AuditSuccess,
+		}
+		rw := &auditResponseWriter{ResponseWriter: w}
+		next(rw, r.WithContext(context.WithValue(r.Context(), auditEntryKey{}, entry)))
+
+		entry.DurationMs = time.Since(start).Milliseconds()
+		if rw.status >= http.StatusBadRequest {
+			var resp struct {
+				Error string `json:"error"`
+			}
+			entry.Outcome, entry.Error = dao.AuditError, strings.TrimSpace(rw.body.String())
+			if json.Unmarshal(rw.body.Bytes(), &resp) == nil && resp.Error != "" {
+				entry.Error = resp.Error
+			}
+		}
+		writeAuditLog(entry)
+	}
+}
+
+// setAuditScope 记录管理接口调用涉及的集群和命名空间
+func setAuditScope(r *http.Request, clusterName, namespace string) {
+	if entry, ok := r.Context().Value(auditEntryKey{}).(*dao.AuditLog); ok {
+		entry.ClusterName, entry.Namespace = clusterName, namespace
+	}
+}
+
+// writeAuditLog 写入审计记录，失败时仅记录日志
+func writeAuditLog(entry *dao.AuditLog) {
+	if err := dao.CreateAuditLog(entry); err != nil {
+		klog.Errorf("[AUDIT] write audit log failed: tool=%s, sid=%s, err=%v", entry.Tool, entry.SessionID, err)
+	}
+}
+
+// resultText 拼接工具结果中的文本内容
+func resultText(result *mcp.CallToolResult) string {
+	var texts []string
+	for _, c := range result.Content {
+		if tc, ok := mcp.AsTextContent(c); ok {
+			texts = append(texts, tc.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// parseAuditTime 解析时间参数，支持 RFC3339 时间或相对当前的时长（如 30m、24h）
+func parseAuditTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("时间 %q 格式错误，应为 RFC3339 或时长（如 24h）", value)
+	}
+	return t, nil
+}
+
+// registerAuditTools 注册审计查询工具，内置策略仅 admin 可用
+func (s *MCPServer) registerAuditTools() {
+	// query_audit_log
+	s.registerTool(toolSpec{
+		Name:        "query_audit_log",
+		Description: "Search the audit log of tool invocations, latest first",
+		Method:      "GET",
+		Path:        "/audit_log",
+		Params: []toolParam{
+			{Name: "user_id", Type: paramString, Description: "调用方用户 ID"},
+			{Name: "tool", Type: paramString, Description: "工具名"},
+			{Name: "cluster", Type: paramString, Description: "集群名称"},
+			{Name: "namespace", Type: paramString, Description: "命名空间"},
+			{Name: "outcome", Type: paramString, Description: "调用结果", Enum: []string{dao.AuditSuccess, dao.AuditError}},
+			{Name: "since", Type: paramString, Description: "起始时间，RFC3339 或相对时长（如 24h）"},
+			{Name: "until", Type: paramString, Description: "结束时间，RFC3339 或相对时长"},
+			{Name: "limit", Type: paramNumber, Description: fmt.Sprintf("返回条数上限，默认 %d，最大 %d", defaultAuditQueryLimit, maxAuditQueryLimit)},
+		},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			now := time.Now()
+			since, err := parseAuditTime(args.String("since"), now)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			until, err := parseAuditTime(args.String("until"), now)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			limit := args.Int("limit", defaultAuditQueryLimit)
+			if limit <= 0 || limit > maxAuditQueryLimit {
+				limit = maxAuditQueryLimit
+			}
+			logs, err := dao.QueryAuditLogs(dao.AuditLogQuery{
+				UserID:      args.String("user_id"),
+				Tool:        args.String("tool"),
+				ClusterName: args.String("cluster"),
+				Namespace:   args.String("namespace"),
+				Outcome:     args.String("outcome"),
+				Since:       since,
+				Until:       until,
+				Limit:       limit,
+			})
+			if err != nil {
+				return mcp.NewToolResultError("查询审计日志失败: " + err.Error()), nil
+			}
+			return jsonResult(logs)
+		},
+	})
+}
@@ -0,0 +1,435 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/relaxyabc/k8s-helper/common"
+	"github.com/relaxyabc/k8s-helper/dao"
+	"github.com/relaxyabc/k8s-helper/tools"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// maxKubeConfigSize 上传 kubeconfig 的大小上限
+	maxKubeConfigSize = 1 << 20
+	// maxClusterRequestSize 集群管理请求体的大小上限，kubeconfig 之外预留 CA 证书和表单字段的空间
+	maxClusterRequestSize = maxKubeConfigSize + 64<<10
+)
+
+// clusterInput 注册或更新集群的参数，字段为 nil 表示不修改，json 字段名与工具参数名一致
+type clusterInput struct {
+	IP                    *string `json:"ip,omitempty"`
+	KubeConfig            *string `json:"kube_config,omitempty"`
+	InsecureSkipTLSVerify *bool   `json:"insecure_skip_tls_verify,omitempty"`
+	CABundle              *string `json:"ca_bundle,omitempty"`
+	Protected             *bool   `json:"protected,omitempty"`
+}
+
+// clusterInputFromArgs 从工具参数中读取传入的字段
+func clusterInputFromArgs(args toolArgs) clusterInput {
+	var in clusterInput
+	str := func(key string) *string {
+		if _, ok := args[key]; !ok {
+			return nil
+		}
+		v := args.String(key)
+		return &v
+	}
+	boolean := func(key string) *bool {
+		if _, ok := args[key]; !ok {
+			return nil
+		}
+		v := args.Bool(key, false)
+		return &v
+	}
+	in.IP = str("ip")
+	in.KubeConfig = str("kube_config")
+	in.CABundle = str("ca_bundle")
+	in.InsecureSkipTLSVerify = boolean("insecure_skip_tls_verify")
+	in.Protected = boolean("protected")
+	return in
+}
+
+// toolArgs 转为 update_cluster 的工具参数，HTTP 接口据此创建审批记录
+func (in clusterInput) toolArgs(clusterName string) (toolArgs, error) {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	args := toolArgs{}
+	if err := json.Unmarshal(data, &args); err != nil {
+		return nil, err
+	}
+	args["cluster_name"] = clusterName
+	return args, nil
+}
+
+// clusterChangeApprovalReason 判断更新集群是否需要审批：非 admin 角色、目标集群受保护或修改了 protected 时需要
+func clusterChangeApprovalReason(role, clusterName string, in clusterInput) string {
+	if reason := approvalReason(role, clusterName); reason != "" {
+		return reason
+	}
+	// 走到这里说明集群当前未受保护
+	if in.Protected != nil && *in.Protected {
+		return fmt.Sprintf("修改集群 %s 的 protected 需要审批", clusterName)
+	}
+	return ""
+}
+
+// validateManagedCluster 校验更新、删除的目标集群存在，避免为不存在的集群创建审批
+func validateManagedCluster(args toolArgs) error {
+	_, err := dao.GetCluster(args.String("cluster_name"))
+	return err
+}
+
+// apply 将传入的字段写入集群记录，返回连接信息（kubeconfig/TLS）是否变化
+func (in clusterInput) apply(c *dao.Cluster) bool {
+	changed := false
+	if in.IP != nil {
+		c.IP = *in.IP
+	}
+	if in.KubeConfig != nil && *in.KubeConfig != c.KubeConfig {
+		c.KubeConfig = *in.KubeConfig
+		changed = true
+	}
+	if in.InsecureSkipTLSVerify != nil && *in.InsecureSkipTLSVerify != c.InsecureSkipTLSVerify {
+		c.InsecureSkipTLSVerify = *in.InsecureSkipTLSVerify
+		changed = true
+	}
+	if in.CABundle != nil && *in.CABundle != c.CABundle {
+		c.CABundle = *in.CABundle
+		changed = true
+	}
+	if in.Protected != nil {
+		c.Protected = *in.Protected
+	}
+	return changed
+}
+
+// clusterResult 注册或更新集群的结果，Version 为连通性检查得到的集群版本
+type clusterResult struct {
+	Cluster *dao.Cluster `json:"cluster"`
+	Version string       `json:"version,omitempty"`
+}
+
+// saveCluster 注册（create 为 true）或更新集群，kubeconfig 或 TLS 设置变化时先做连通性检查
+func saveCluster(clusterName string, in clusterInput, create bool) (*clusterResult, error) {
+	if clusterName == "" {
+		return nil, fmt.Errorf("cluster_name 必填")
+	}
+	var c *dao.Cluster
+	if create {
+		if in.KubeConfig == nil || strings.TrimSpace(*in.KubeConfig) == "" {
+			return nil, fmt.Errorf("kube_config 必填")
+		}
+		c = &dao.Cluster{ClusterName: clusterName}
+	} else {
+		var err error
+		if c, err = dao.GetCluster(clusterName); err != nil {
+			return nil, err
+		}
+	}
+	result := &clusterResult{Cluster: c}
+	if in.apply(c) || create {
+		version, err := tools.CheckClusterConnectivity(proxy, dao.ClusterConnection{
+			KubeConfig:            c.KubeConfig,
+			InsecureSkipTLSVerify: c.InsecureSkipTLSVerify,
+			CABundle:              c.CABundle,
+		})
+		if err != nil {
+			return nil, err
+		}
+		result.Version = version
+	}
+	var err error
+	if create {
+		err = dao.CreateCluster(c)
+	} else {
+		err = dao.UpdateCluster(c)
+	}
+	if err != nil {
+		return nil, err
+	}
+	tools.InvalidateClusterClient(clusterName)
+	klog.Infof("[CLUSTER] saved cluster %s, create=%v, version=%s", clusterName, create, result.Version)
+	return result, nil
+}
+
+// deleteCluster 软删除集群并丢弃缓存的 client
+func deleteCluster(clusterName string) error {
+	if err := dao.DeleteCluster(clusterName); err != nil {
+		return err
+	}
+	tools.InvalidateClusterClient(clusterName)
+	klog.Infof("[CLUSTER] deleted cluster %s", clusterName)
+	return nil
+}
+
+// paramManagedCluster 更新、删除集群的目标集群，必须显式指定，不取自会话上下文
+var paramManagedCluster = toolParam{Name: "cluster_name", Type: paramString, Required: true, Description: "集群名称，取自 get_clusters 返回的 cluster_name"}
+
+// clusterParams 注册和更新集群共用的参数
+var clusterParams = []toolParam{
+	{Name: "ip", Type: paramString, Description: "集群 IP 地址"},
+	{Name: "insecure_skip_tls_verify", Type: paramBool, Description: "是否跳过 TLS 校验"},
+	{Name: "ca_bundle", Type: paramString, Description: "额外信任的 CA 证书（PEM）"},
+	{Name: "protected", Type: paramBool, Description: "是否受保护，受保护集群的变更操作需要审批"},
+}
+
+// registerClusterAdminTools 注册集群管理工具，内置策略仅 admin 可用
+func (s *MCPServer) registerClusterAdminTools() {
+	// register_cluster
+	s.registerTool(toolSpec{
+		Name:        "register_cluster",
+		Description: "Register a cluster from a kubeconfig, the kubeconfig is validated with a connectivity check first",
+		Method:      "POST",
+		Path:        "/register_cluster",
+		Params: append([]toolParam{
+			{Name: "cluster_name", Type: paramString, Required: true, Description: "集群名称"},
+			{Name: "kube_config", Type: paramString, Required: true, Description: "kubeconfig 内容"},
+		}, clusterParams...),
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			result, err := saveCluster(args.String("cluster_name"), clusterInputFromArgs(args), true)
+			if err != nil {
+				return mcp.NewToolResultError("注册集群失败: " + err.Error()), nil
+			}
+			return jsonResult(result)
+		},
+	})
+	// update_cluster
+	s.registerTool(toolSpec{
+		Name:        "update_cluster",
+		Description: "Update a registered cluster, only the given fields are changed; a new kubeconfig or TLS setting is validated with a connectivity check first",
+		Method:      "POST",
+		Path:        "/update_cluster",
+		Mutating:    true,
+		Params: append([]toolParam{
+			paramManagedCluster,
+			{Name: "kube_config", Type: paramString, Description: "kubeconfig 内容"},
+		}, clusterParams...),
+		Validate: validateManagedCluster,
+		ApprovalReason: func(role string, args toolArgs) string {
+			return clusterChangeApprovalReason(role, args.String("cluster_name"), clusterInputFromArgs(args))
+		},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			result, err := saveCluster(args.String("cluster_name"), clusterInputFromArgs(args), false)
+			if err != nil {
+				return mcp.NewToolResultError("更新集群失败: " + err.Error()), nil
+			}
+			return jsonResult(result)
+		},
+	})
+	// delete_cluster
+	s.registerTool(toolSpec{
+		Name:        "delete_cluster",
+		Description: "Delete a registered cluster (soft delete)",
+		Method:      "POST",
+		Path:        "/delete_cluster",
+		Mutating:    true,
+		Params:      []toolParam{paramManagedCluster},
+		Validate:    validateManagedCluster,
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			if err := deleteCluster(args.String("cluster_name")); err != nil {
+				return mcp.NewToolResultError("删除集群失败: " + err.Error()), nil
+			}
+			return mcp.NewToolResultText("集群已删除"), nil
+		},
+	})
+}
+
+// RegisterClusterHandlers 注册集群管理 HTTP 接口，调用方角色需有对应工具的权限及集群范围：
+// GET /admin/clusters 查询集群列表（get_clusters），不含 kubeconfig
+// POST /admin/clusters 注册集群（register_cluster），JSON 或 multipart 表单（kubeconfig 文件字段 kubeconfig）
+// PUT /admin/clusters/{name} 更新集群（update_cluster），JSON，仅修改传入的字段
+// DELETE /admin/clusters/{name} 删除集群（delete_cluster）
+// 更新、删除需要审批时（同 update_cluster、delete_cluster 工具）返回 202 和待审批记录
+func (s *MCPServer) RegisterClusterHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("GET /admin/clusters", func(w http.ResponseWriter, r *http.Request) {
+		_, role, ok := s.authorizeHTTP(w, r, "get_clusters")
+		if !ok {
+			return
+		}
+		clusters, err := dao.ListClusters()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		visible := []dao.Cluster{}
+		for _, c := range clusters {
+			if rbacPolicy.AllowCluster(role, c.ClusterName) {
+				visible = append(visible, c)
+			}
+		}
+		writeJSON(w, http.StatusOK, visible)
+	})
+	mux.HandleFunc("POST /admin/clusters", s.auditHTTP("register_cluster", func(w http.ResponseWriter, r *http.Request) {
+		_, role, ok := s.authorizeHTTP(w, r, "register_cluster")
+		if !ok {
+			return
+		}
+		clusterName, in, err := parseClusterRequest(w, r)
+		if err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		setAuditScope(r, clusterName, "")
+		if !authorizeHTTPCluster(w, role, clusterName) {
+			return
+		}
+		result, err := saveCluster(clusterName, in, true)
+		if err != nil {
+			writeClusterError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, result)
+	}))
+	mux.HandleFunc("PUT /admin/clusters/{name}", s.auditHTTP("update_cluster", func(w http.ResponseWriter, r *http.Request) {
+		setAuditScope(r, r.PathValue("name"), "")
+		_, role, ok := s.authorizeHTTP(w, r, "update_cluster")
+		if !ok || !authorizeHTTPCluster(w, role, r.PathValue("name")) {
+			return
+		}
+		var in clusterInput
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxClusterRequestSize)).Decode(&in); err != nil {
+			writeRequestError(w, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+		if _, err := dao.GetCluster(r.PathValue("name")); err != nil {
+			writeClusterError(w, err)
+			return
+		}
+		if reason := clusterChangeApprovalReason(role, r.PathValue("name"), in); reason != "" {
+			args, err := in.toolArgs(r.PathValue("name"))
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			s.writeApproval(w, r, "update_cluster", args, reason)
+			return
+		}
+		result, err := saveCluster(r.PathValue("name"), in, false)
+		if err != nil {
+			writeClusterError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}))
+	mux.HandleFunc("DELETE /admin/clusters/{name}", s.auditHTTP("delete_cluster", func(w http.ResponseWriter, r *http.Request) {
+		setAuditScope(r, r.PathValue("name"), "")
+		_, role, ok := s.authorizeHTTP(w, r, "delete_cluster")
+		if !ok || !authorizeHTTPCluster(w, role, r.PathValue("name")) {
+			return
+		}
+		if _, err := dao.GetCluster(r.PathValue("name")); err != nil {
+			writeClusterError(w, err)
+			return
+		}
+		if reason := approvalReason(role, r.PathValue("name")); reason != "" {
+			s.writeApproval(w, r, "delete_cluster", toolArgs{"cluster_name": r.PathValue("name")}, reason)
+			return
+		}
+		if err := deleteCluster(r.PathValue("name")); err != nil {
+			writeClusterError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+// parseClusterRequest 解析注册集群请求，支持 JSON 和上传 kubeconfig 文件的 multipart 表单，
+// 请求体超过 maxClusterRequestSize 时返回的错误包含 *http.MaxBytesError
+func parseClusterRequest(w http.ResponseWriter, r *http.Request) (string, clusterInput, error) {
+	var in clusterInput
+	r.Body = http.MaxBytesReader(w, r.Body, maxClusterRequestSize)
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		var body struct {
+			ClusterName string `json:"cluster_name"`
+			clusterInput
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return "", in, fmt.Errorf("invalid request body: %w", err)
+		}
+		return body.ClusterName, body.clusterInput, nil
+	}
+	if err := r.ParseMultipartForm(maxKubeConfigSize); err != nil {
+		return "", in, fmt.Errorf("invalid multipart form: %w", err)
+	}
+	file, _, err := r.FormFile("kubeconfig")
+	if err != nil {
+		return "", in, fmt.Errorf("kubeconfig file is required: %w", err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxKubeConfigSize+1))
+	if err != nil {
+		return "", in, err
+	}
+	if len(data) > maxKubeConfigSize {
+		return "", in, fmt.Errorf("kubeconfig file exceeds %d bytes", maxKubeConfigSize)
+	}
+	kubeconfig := string(data)
+	in.KubeConfig = &kubeconfig
+	if v := r.FormValue("ip"); v != "" {
+		in.IP = &v
+	}
+	if v := r.FormValue("ca_bundle"); v != "" {
+		in.CABundle = &v
+	}
+	for key, target := range map[string]**bool{"insecure_skip_tls_verify": &in.InsecureSkipTLSVerify, "protected": &in.Protected} {
+		if v := r.FormValue(key); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return "", in, fmt.Errorf("invalid %s: %s", key, v)
+			}
+			*target = &b
+		}
+	}
+	return r.FormValue("cluster_name"), in, nil
+}
+
+// writeApproval 为管理接口的变更请求创建待审批记录，返回 202
+func (s *MCPServer) writeApproval(w http.ResponseWriter, r *http.Request, toolName string, args toolArgs, reason string) {
+	sid, _ := r.Context().Value(common.ContextKeyMcpSession).(string)
+	a, err := s.createApproval(sid, s.mutatingTools[toolName], args, reason)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusAccepted, approvalPending(a))
+}
+
+// authorizeHTTPCluster 校验管理接口调用方角色是否可访问指定集群
+func authorizeHTTPCluster(w http.ResponseWriter, role, clusterName string) bool {
+	if !rbacPolicy.AllowCluster(role, clusterName) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": fmt.Sprintf("无权访问集群 %s（角色: %s）", clusterName, role)})
+		return false
+	}
+	return true
+}
+
+// writeRequestError 写出解析请求体失败的响应，请求体超过大小上限时返回 413
+func writeRequestError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		status = http.StatusRequestEntityTooLarge
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// writeClusterError 按错误类型返回集群管理接口的状态码
+func writeClusterError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, dao.ErrClusterNotFound) {
+		status = http.StatusNotFound
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,429 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/relaxyabc/k8s-helper/dao"
+	"github.com/relaxyabc/k8s-helper/tools"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// registerK8sTools 注册集群与 K8s 资源相关工具
+func (s *MCPServer) registerK8sTools() {
+	// get_clusters
+	s.registerTool(toolSpec{
+		Name:        "get_clusters",
+		Description: "Get all clusters from database",
+		Method:      "GET",
+		Path:        "/clusters",
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			result, err := dao.GetClusterInfos()
+			if err != nil {
+				return mcp.NewToolResultError("查询数据库失败: " + err.Error()), nil
+			}
+			return jsonResult(s.filterClustersByRole(ctx, result))
+		},
+	})
+	// get_namespaces
+	s.registerTool(toolSpec{
+		Name:        "get_namespaces",
+		Description: "Get namespaces list for a cluster",
+		Method:      "GET",
+		Path:        "/namespaces",
+		Params:      []toolParam{paramClusterName},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			nsList, err := tools.GetNamespacesTool(proxy, args.String("cluster_name"))
+			if err != nil {
+				return mcp.NewToolResultError("获取 namespace 失败: " + err.Error()), nil
+			}
+			return jsonResult(s.filterNamespacesByRole(ctx, nsList))
+		},
+	})
+	// get_pods
+	s.registerTool(toolSpec{
+		Name:        "get_pods",
+		Description: "Get pods in a namespace for a cluster, with phase, ready containers, restarts, last termination reason, node, pod IP, age and owner",
+		Method:      "GET",
+		Path:        "/pods",
+		Params: []toolParam{
+			paramClusterName, paramNamespace,
+			{Name: "label_selector", Type: paramString, Description: "label selector，如 app=nginx,tier!=cache"},
+			{Name: "field_selector", Type: paramString, Description: "field selector，如 status.phase!=Running,spec.nodeName=node-1"},
+		},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			pods, err := tools.GetPodsTool(proxy, args.String("cluster_name"), args.String("namespace"), args.String("label_selector"), args.String("field_selector"))
+			if err != nil {
+				return mcp.NewToolResultError("获取 pods 失败: " + err.Error()), nil
+			}
+			return jsonResult(pods)
+		},
+	})
+	// get_deployments
+	s.registerTool(toolSpec{
+		Name:        "get_deployments",
+		Description: "Get deployments in a namespace for a cluster",
+		Method:      "GET",
+		Path:        "/deployments",
+		Params:      []toolParam{paramClusterName, paramNamespace},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			deployments, err := tools.GetDeploymentsTool(proxy, args.String("cluster_name"), args.String("namespace"))
+			if err != nil {
+				return mcp.NewToolResultError("获取 deployments 失败: " + err.Error()), nil
+			}
+			return jsonResult(deployments)
+		},
+	})
+	// get_daemonsets
+	s.registerTool(toolSpec{
+		Name:        "get_daemonsets",
+		Description: "Get daemonsets in a namespace for a cluster",
+		Method:      "GET",
+		Path:        "/daemonsets",
+		Params:      []toolParam{paramClusterName, paramNamespace},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			daemonsets, err := tools.GetDaemonSetsTool(proxy, args.String("cluster_name"), args.String("namespace"))
+			if err != nil {
+				return mcp.NewToolResultError("获取 daemonsets 失败: " + err.Error()), nil
+			}
+			return jsonResult(daemonsets)
+		},
+	})
+	// rollout_restart_deployment
+	s.registerTool(toolSpec{
+		Name:        "rollout_restart_deployment",
+		Description: "滚动重启指定 Deployment",
+		Method:      "POST",
+		Path:        "/rollout_restart_deployment",
+		Mutating:    true,
+		Params:      []toolParam{paramClusterName, paramNamespace, paramName("Deployment"), paramDryRun},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			preview, err := tools.RolloutRestartDeploymentTool(proxy, args.String("cluster_name"), args.String("namespace"), args.String("name"), args.Bool("dry_run", false))
+			if err != nil {
+				return mcp.NewToolResultError("滚动重启 Deployment 失败: " + err.Error()), nil
+			}
+			if preview.DryRun {
+				return jsonResult(preview)
+			}
+			return mcp.NewToolResultText("Deployment rollout restarted successfully, call rollout_status to wait for it to converge."), nil
+		},
+	})
+	// rollout_restart_daemonset
+	s.registerTool(toolSpec{
+		Name:        "rollout_restart_daemonset",
+		Description: "滚动重启指定 DaemonSet",
+		Method:      "POST",
+		Path:        "/rollout_restart_daemonset",
+		Mutating:    true,
+		Params:      []toolParam{paramClusterName, paramNamespace, paramName("DaemonSet"), paramDryRun},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			preview, err := tools.RolloutRestartDaemonSetTool(proxy, args.String("cluster_name"), args.String("namespace"), args.String("name"), args.Bool("dry_run", false))
+			if err != nil {
+				return mcp.NewToolResultError("滚动重启 DaemonSet 失败: " + err.Error()), nil
+			}
+			if preview.DryRun {
+				return jsonResult(preview)
+			}
+			return mcp.NewToolResultText("DaemonSet 滚动重启成功"), nil
+		},
+	})
+	// get_k8s_version
+	s.registerTool(toolSpec{
+		Name:        "get_k8s_version",
+		Description: "Get k8s version for a cluster",
+		Method:      "GET",
+		Path:        "/k8s_version",
+		Params:      []toolParam{paramClusterName},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			version, err := tools.GetK8sVersionTool(proxy, args.String("cluster_name"))
+			if err != nil {
+				return mcp.NewToolResultError("获取 k8s 版本失败: " + err.Error()), nil
+			}
+			return mcp.NewToolResultText(version), nil
+		},
+	})
+	// get_configmaps
+	s.registerTool(toolSpec{
+		Name:        "get_configmaps",
+		Description: "Get configmaps in a namespace for a cluster",
+		Method:      "GET",
+		Path:        "/configmaps",
+		Params:      []toolParam{paramClusterName, paramNamespace},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			configmaps, err := tools.GetConfigMapsTool(proxy, args.String("cluster_name"), args.String("namespace"))
+			if err != nil {
+				return mcp.NewToolResultError("获取 configmaps 失败: " + err.Error()), nil
+			}
+			return jsonResult(configmaps)
+		},
+	})
+	// configmap_detail
+	s.registerTool(toolSpec{
+		Name:        "configmap_detail",
+		Description: "Get detail of a configmap in a namespace for a cluster",
+		Method:      "GET",
+		Path:        "/configmap_detail",
+		Params:      []toolParam{paramClusterName, paramNamespace, paramName("ConfigMap")},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			data, err := tools.GetConfigMapDetailTool(proxy, args.String("cluster_name"), args.String("namespace"), args.String("name"))
+			if err != nil {
+				return mcp.NewToolResultError("获取 configmap 详情失败: " + err.Error()), nil
+			}
+			return jsonResult(data)
+		},
+	})
+	// get_pod_logs
+	s.registerTool(toolSpec{
+		Name:        "get_pod_logs",
+		Description: "Get logs of a pod, output is capped and keeps the latest lines",
+		Method:      "GET",
+		Path:        "/pod_logs",
+		Params: []toolParam{
+			paramClusterName, paramNamespace, paramName("Pod"),
+			{Name: "container", Type: paramString, Description: "容器名，多容器 Pod 必填"},
+			{Name: "tail_lines", Type: paramNumber, Description: fmt.Sprintf("返回最后多少行，未指定 tail_lines 和 since_seconds 时默认 %d", tools.DefaultPodLogTailLines)},
+			{Name: "since_seconds", Type: paramNumber, Description: "只返回最近多少秒的日志"},
+			{Name: "previous", Type: paramBool, Description: "读取上一个（已崩溃）容器实例的日志"},
+			{Name: "timestamps", Type: paramBool, Description: "每行日志带时间戳"},
+			{Name: "max_bytes", Type: paramNumber, Description: fmt.Sprintf("返回的字节上限，默认 %d，最大 %d", tools.DefaultPodLogMaxBytes, tools.MaxPodLogBytes)},
+		},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			opts := tools.PodLogOptions{
+				Container:    args.String("container"),
+				TailLines:    int64(args.Int("tail_lines", 0)),
+				SinceSeconds: int64(args.Int("since_seconds", 0)),
+				Previous:     args.Bool("previous", false),
+				Timestamps:   args.Bool("timestamps", false),
+				MaxBytes:     args.Int("max_bytes", 0),
+			}
+			result, err := tools.GetPodLogsTool(proxy, args.String("cluster_name"), args.String("namespace"), args.String("name"), opts)
+			if err != nil {
+				return mcp.NewToolResultError("获取 Pod 日志失败: " + err.Error()), nil
+			}
+			if result.Truncated {
+				return mcp.NewToolResultText("[日志超出字节上限，仅保留最新部分]\n" + result.Logs), nil
+			}
+			return mcp.NewToolResultText(result.Logs), nil
+		},
+	})
+	// get_events
+	s.registerTool(toolSpec{
+		Name:        "get_events",
+		Description: "Get events of a namespace or of one involved object, deduplicated by object and reason with counts, latest first",
+		Method:      "GET",
+		Path:        "/events",
+		Params: []toolParam{
+			paramClusterName, paramNamespace,
+			{Name: "kind", Type: paramString, Description: "关联对象类型，如 Pod、Deployment，与 name 配合查询单个对象的事件"},
+			{Name: "name", Type: paramString, Description: "关联对象名称"},
+			{Name: "type", Type: paramString, Description: "事件类型", Enum: []string{"Warning", "Normal"}},
+			{Name: "limit", Type: paramNumber, Description: fmt.Sprintf("返回条数上限，默认 %d", tools.DefaultEventLimit)},
+		},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			events, err := tools.GetEventsTool(proxy, args.String("cluster_name"), tools.EventQuery{
+				Namespace: args.String("namespace"),
+				Kind:      args.String("kind"),
+				Name:      args.String("name"),
+				Type:      args.String("type"),
+				Limit:     args.Int("limit", 0),
+			})
+			if err != nil {
+				return mcp.NewToolResultError("获取 events 失败: " + err.Error()), nil
+			}
+			return jsonResult(events)
+		},
+	})
+	// describe_resource
+	s.registerTool(toolSpec{
+		Name:        "describe_resource",
+		Description: "Describe a workload like kubectl describe: spec highlights, replica counts, selector, container images, resource requests/limits, status conditions and recent events",
+		Method:      "GET",
+		Path:        "/describe",
+		Params: []toolParam{
+			paramClusterName, paramNamespace,
+			{Name: "kind", Type: paramString, Required: true, Description: "资源类型", Enum: tools.DescribeKinds},
+			paramName("资源"),
+		},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			desc, err := tools.DescribeResourceTool(proxy, args.String("cluster_name"), args.String("kind"), args.String("namespace"), args.String("name"))
+			if err != nil {
+				return mcp.NewToolResultError("describe 资源失败: " + err.Error()), nil
+			}
+			return jsonResult(desc)
+		},
+	})
+	// list_resources
+	s.registerTool(toolSpec{
+		Name:        "list_resources",
+		Description: "List resources of any kind via discovery, including CRDs; resource accepts a kind, plural or short name such as Deployment, sts, ing or certificates.cert-manager.io; managedFields are stripped",
+		Method:      "GET",
+		Path:        "/resources",
+		Params: []toolParam{
+			paramClusterName, paramResource, paramAPIVersion,
+			{Name: "namespace", Type: paramString, Context: sessionDataNamespace, Description: "命名空间，传空串时查询全部命名空间；集群级资源忽略"},
+			{Name: "label_selector", Type: paramString, Description: "label selector，如 app=nginx"},
+			{Name: "field_selector", Type: paramString, Description: "field selector，如 metadata.name=web"},
+			{Name: "limit", Type: paramNumber, Description: fmt.Sprintf("单页条数，默认 %d", tools.DefaultResourceListLimit)},
+			{Name: "continue", Type: paramString, Description: "上一页结果 metadata.continue 的值，用于翻页"},
+			paramOutput,
+		},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			list, err := tools.ListResourcesTool(proxy, args.String("cluster_name"), tools.ResourceQuery{
+				Resource:      args.String("resource"),
+				APIVersion:    args.String("api_version"),
+				Namespace:     args.String("namespace"),
+				LabelSelector: args.String("label_selector"),
+				FieldSelector: args.String("field_selector"),
+				Limit:         int64(args.Int("limit", 0)),
+				Continue:      args.String("continue"),
+			})
+			if err != nil {
+				return mcp.NewToolResultError("查询资源列表失败: " + err.Error()), nil
+			}
+			s.filterResourcesByRole(ctx, list)
+			return encodeResourceResult(list.UnstructuredContent(), args.String("output"))
+		},
+	})
+	// get_resource
+	s.registerTool(toolSpec{
+		Name:        "get_resource",
+		Description: "Get one resource of any kind via discovery, including CRDs; resource accepts a kind, plural or short name; managedFields are stripped",
+		Method:      "GET",
+		Path:        "/resource",
+		Params: []toolParam{
+			paramClusterName, paramResource, paramAPIVersion,
+			{Name: "namespace", Type: paramString, Context: sessionDataNamespace, Description: "命名空间，命名空间级资源必填"},
+			paramName("资源"),
+			paramOutput,
+		},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			obj, err := tools.GetResourceTool(proxy, args.String("cluster_name"), tools.ResourceQuery{
+				Resource:   args.String("resource"),
+				APIVersion: args.String("api_version"),
+				Namespace:  args.String("namespace"),
+				Name:       args.String("name"),
+			})
+			if err != nil {
+				return mcp.NewToolResultError("查询资源失败: " + err.Error()), nil
+			}
+			return encodeResourceResult(obj.UnstructuredContent(), args.String("output"))
+		},
+	})
+	// scale_workload
+	s.registerTool(toolSpec{
+		Name:        "scale_workload",
+		Description: "Scale a Deployment or StatefulSet through the scale subresource, the response records the previous replica count; protected namespaces refuse to scale below their configured minimum",
+		Method:      "POST",
+		Path:        "/scale_workload",
+		Mutating:    true,
+		Params: []toolParam{
+			paramClusterName, paramNamespace,
+			{Name: "kind", Type: paramString, Required: true, Description: "资源类型", Enum: tools.ScaleKinds},
+			paramName("资源"),
+			{Name: "replicas", Type: paramNumber, Required: true, Description: "目标副本数"},
+			paramDryRun,
+		},
+		Validate: func(args toolArgs) error {
+			if _, err := tools.NormalizeScaleKind(args.String("kind")); err != nil {
+				return err
+			}
+			replicas, ok := args.Int32("replicas")
+			if !ok || replicas < 0 {
+				return fmt.Errorf("replicas 必须为 0 到 %d 之间的整数", math.MaxInt32)
+			}
+			namespace := args.String("namespace")
+			if minReplicas := rbacPolicy.MinReplicas(args.String("cluster_name"), namespace); replicas < minReplicas {
+				return fmt.Errorf("命名空间 %s 受保护，副本数不能低于 %d", namespace, minReplicas)
+			}
+			return nil
+		},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			clusterName, namespace := args.String("cluster_name"), args.String("namespace")
+			replicas, _ := args.Int32("replicas")
+			result, err := tools.ScaleWorkloadTool(proxy, clusterName, args.String("kind"), namespace, args.String("name"), replicas, args.Bool("dry_run", false))
+			if err != nil {
+				return mcp.NewToolResultError("扩缩容失败: " + err.Error()), nil
+			}
+			return jsonResult(result)
+		},
+	})
+	// rollout_status
+	s.registerTool(toolSpec{
+		Name:        "rollout_status",
+		Description: "Wait for a Deployment rollout to converge or time out, reporting progress from its ReplicaSets and conditions",
+		Method:      "GET",
+		Path:        "/rollout_status",
+		Params: []toolParam{
+			paramClusterName, paramNamespace, paramName("Deployment"),
+			{Name: "timeout_seconds", Type: paramNumber, Description: fmt.Sprintf("最长等待时长，默认 %d，最大 %d，为 0 时只查询一次", tools.DefaultRolloutWaitSeconds, tools.MaxRolloutWaitSeconds)},
+		},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			timeout := args.Int("timeout_seconds", tools.DefaultRolloutWaitSeconds)
+			if timeout < 0 {
+				return mcp.NewToolResultError("timeout_seconds 不能为负数"), nil
+			}
+			if timeout > tools.MaxRolloutWaitSeconds {
+				timeout = tools.MaxRolloutWaitSeconds
+			}
+			status, err := tools.RolloutStatusTool(proxy, args.String("cluster_name"), args.String("namespace"), args.String("name"), time.Duration(timeout)*time.Second)
+			if err != nil {
+				return mcp.NewToolResultError("查询 rollout 状态失败: " + err.Error()), nil
+			}
+			return jsonResult(status)
+		},
+	})
+	// rollout_history
+	s.registerTool(toolSpec{
+		Name:        "rollout_history",
+		Description: "List revisions of a Deployment with their change-cause and images",
+		Method:      "GET",
+		Path:        "/rollout_history",
+		Params:      []toolParam{paramClusterName, paramNamespace, paramName("Deployment")},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			revisions, err := tools.RolloutHistoryTool(proxy, args.String("cluster_name"), args.String("namespace"), args.String("name"))
+			if err != nil {
+				return mcp.NewToolResultError("查询 rollout 历史失败: " + err.Error()), nil
+			}
+			return jsonResult(revisions)
+		},
+	})
+	// rollout_undo
+	s.registerTool(toolSpec{
+		Name:        "rollout_undo",
+		Description: "Roll a Deployment back to a revision like kubectl rollout undo, defaults to the previous revision",
+		Method:      "POST",
+		Path:        "/rollout_undo",
+		Mutating:    true,
+		Params: []toolParam{
+			paramClusterName, paramNamespace, paramName("Deployment"),
+			{Name: "to_revision", Type: paramNumber, Description: "目标版本号，取自 rollout_history，默认回滚到上一个版本"},
+			paramDryRun,
+		},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			result, err := tools.RolloutUndoTool(proxy, args.String("cluster_name"), args.String("namespace"), args.String("name"), int64(args.Int("to_revision", 0)), args.Bool("dry_run", false))
+			if err != nil {
+				return mcp.NewToolResultError("回滚 Deployment 失败: " + err.Error()), nil
+			}
+			return jsonResult(result)
+		},
+	})
+}
+
+// 通用资源工具的参数定义
+var (
+	paramResource   = toolParam{Name: "resource", Type: paramString, Required: true, Description: "资源类型：kind、复数名或简称，如 Deployment、sts、ing、certificates.cert-manager.io"}
+	paramAPIVersion = toolParam{Name: "api_version", Type: paramString, Description: "可选，指定 group/version，如 apps/v1、networking.k8s.io/v1"}
+	paramOutput     = toolParam{Name: "output", Type: paramString, Description: "输出格式，默认 yaml", Enum: []string{tools.OutputYAML, tools.OutputJSON}}
+)
+
+// encodeResourceResult 按 output 参数输出资源
+func encodeResourceResult(content map[string]any, output string) (*mcp.CallToolResult, error) {
+	text, err := tools.EncodeResource(content, output)
+	if err != nil {
+		return mcp.NewToolResultError("序列化失败: " + err.Error()), nil
+	}
+	return mcp.NewToolResultText(text), nil
+}
@@ -0,0 +1,288 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/relaxyabc/k8s-helper/tools"
+
+	"github.com/google/uuid"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// podLogsNotificationMethod 日志推送通知的 method
+	podLogsNotificationMethod = "notifications/pod_logs"
+	// defaultFollowSeconds 未指定时长时 follow 的默认时长
+	defaultFollowSeconds = 300
+	// maxFollowSeconds follow 的最大时长
+	maxFollowSeconds = 1800
+	// maxFollowsPerSession 单个 session 同时进行的 follow 上限
+	maxFollowsPerSession = 3
+	// followFlushInterval 日志批量推送间隔
+	followFlushInterval = 2 * time.Second
+	// followBatchLines 单批推送的最大行数
+	followBatchLines = 100
+)
+
+var (
+	errFollowStopped  = errors.New("stopped by stop_pod_logs")
+	errFollowTimeout  = errors.New("time limit reached")
+	errSessionClosed  = errors.New("session closed")
+	errStreamFinished = errors.New("log stream finished")
+)
+
+// logFollow 一个进行中的日志 follow
+type logFollow struct {
+	ID          string
+	NotifySID   string // 接收通知的 MCP 会话 ID
+	AppSID      string // HTTPSessionManager 中的会话 ID，可能为空
+	ClusterName string
+	Namespace   string
+	Pod         string
+	cancel      context.CancelCauseFunc
+	startedAt   time.Time
+	sentLines   int
+}
+
+// appSessionIDFromContext 获取 SSE 上下文中的应用 sessionId
+func appSessionIDFromContext(ctx context.Context) string {
+	sid, _ := ctx.Value(AppSessionIDKey{}).(string)
+	return sid
+}
+
+// addLogFollow 登记 follow，超出单 session 上限时返回错误
+func (s *MCPServer) addLogFollow(f *logFollow) error {
+	s.logFollowsMutex.Lock()
+	defer s.logFollowsMutex.Unlock()
+	count := 0
+	for _, existing := range s.logFollows {
+		if existing.NotifySID == f.NotifySID {
+			count++
+		}
+	}
+	if count >= maxFollowsPerSession {
+		return fmt.Errorf("每个会话最多同时 follow %d 个日志流，请先调用 stop_pod_logs", maxFollowsPerSession)
+	}
+	s.logFollows[f.ID] = f
+	return nil
+}
+
+// removeLogFollow 注销 follow
+func (s *MCPServer) removeLogFollow(id string) {
+	s.logFollowsMutex.Lock()
+	defer s.logFollowsMutex.Unlock()
+	delete(s.logFollows, id)
+}
+
+// stopLogFollow 停止当前会话发起的指定 follow
+func (s *MCPServer) stopLogFollow(id, notifySID string) bool {
+	s.logFollowsMutex.Lock()
+	defer s.logFollowsMutex.Unlock()
+	f, ok := s.logFollows[id]
+	if !ok || f.NotifySID != notifySID {
+		return false
+	}
+	f.cancel(errFollowStopped)
+	return true
+}
+
+// stopSessionLogFollows 停止指定会话的全部 follow，会话注销或过期时调用
+func (s *MCPServer) stopSessionLogFollows(sessionID string) {
+	s.logFollowsMutex.Lock()
+	defer s.logFollowsMutex.Unlock()
+	for _, f := range s.logFollows {
+		if f.NotifySID == sessionID || f.AppSID == sessionID {
+			f.cancel(errSessionClosed)
+		}
+	}
+}
+
+// runLogFollow 读取日志流并按批推送给会话，直到被停止、超时、会话结束或日志流结束
+func (s *MCPServer) runLogFollow(ctx context.Context, f *logFollow, opts tools.PodLogOptions) {
+	defer s.removeLogFollow(f.ID)
+	stream, err := tools.FollowPodLogsTool(ctx, proxy, f.ClusterName, f.Namespace, f.Pod, opts)
+	if err != nil {
+		f.cancel(err)
+		s.sendFollowDone(f, err)
+		return
+	}
+	defer stream.Close()
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stream)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+		// 读取失败（如单行超过 1MB）以错误结束，先于 errStreamFinished 设置取消原因
+		if err := scanner.Err(); err != nil {
+			f.cancel(fmt.Errorf("读取日志流失败: %w", err))
+		}
+	}()
+
+	ticker := time.NewTicker(followFlushInterval)
+	defer ticker.Stop()
+	var batch []string
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		err := s.server.SendNotificationToSpecificClient(f.NotifySID, podLogsNotificationMethod, map[string]any{
+			"follow_id": f.ID,
+			"pod":       f.Pod,
+			"namespace": f.Namespace,
+			"lines":     batch,
+		})
+		if errors.Is(err, server.ErrSessionNotFound) || errors.Is(err, server.ErrSessionNotInitialized) {
+			f.cancel(errSessionClosed)
+		} else if err != nil {
+			klog.Warningf("[LOG_FOLLOW] follow_id=%s send failed: %v", f.ID, err)
+		} else {
+			f.sentLines += len(batch)
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				flush()
+				// 停止、超时、会话结束或读取失败也会关闭日志流，此时以 context 的取消原因为准
+				f.cancel(errStreamFinished)
+				s.sendFollowDone(f, context.Cause(ctx))
+				return
+			}
+			batch = append(batch, line)
+			if len(batch) >= followBatchLines {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-ctx.Done():
+			flush()
+			s.sendFollowDone(f, context.Cause(ctx))
+			return
+		}
+	}
+}
+
+// followEndCause 返回 follow 结束原因的代码
+func followEndCause(reason error) string {
+	switch {
+	case errors.Is(reason, errFollowTimeout):
+		return "timeout"
+	case errors.Is(reason, errFollowStopped):
+		return "stopped"
+	case errors.Is(reason, errStreamFinished):
+		return "stream_finished"
+	case errors.Is(reason, errSessionClosed):
+		return "session_closed"
+	default:
+		return "error"
+	}
+}
+
+// sendFollowDone 推送 follow 结束通知，cause 为结束原因代码，reason 为详细说明
+func (s *MCPServer) sendFollowDone(f *logFollow, reason error) {
+	klog.Infof("[LOG_FOLLOW] follow_id=%s, sid=%s, pod=%s/%s finished: %v, lines=%d", f.ID, f.NotifySID, f.Namespace, f.Pod, reason, f.sentLines)
+	if errors.Is(reason, errSessionClosed) {
+		return
+	}
+	_ = s.server.SendNotificationToSpecificClient(f.NotifySID, podLogsNotificationMethod, map[string]any{
+		"follow_id": f.ID,
+		"done":      true,
+		"cause":     followEndCause(reason),
+		"reason":    reason.Error(),
+		"lines":     f.sentLines,
+	})
+}
+
+// registerLogFollowTools 注册日志 follow 相关工具，仅 SSE 模式可推送通知
+func (s *MCPServer) registerLogFollowTools() {
+	// follow_pod_logs
+	s.registerTool(toolSpec{
+		Name:        "follow_pod_logs",
+		Description: fmt.Sprintf("Follow logs of a pod, new lines are pushed to this session as %s notifications until stop_pod_logs is called or the time limit is reached", podLogsNotificationMethod),
+		Method:      "POST",
+		Path:        "/follow_pod_logs",
+		Params: []toolParam{
+			paramClusterName, paramNamespace, paramName("Pod"),
+			{Name: "container", Type: paramString, Description: "容器名，多容器 Pod 必填"},
+			{Name: "tail_lines", Type: paramNumber, Description: "开始 follow 前先推送最后多少行，默认 0"},
+			{Name: "timestamps", Type: paramBool, Description: "每行日志带时间戳"},
+			{Name: "timeout_seconds", Type: paramNumber, Description: fmt.Sprintf("follow 时长（秒），须大于 0，默认 %d，最大 %d", defaultFollowSeconds, maxFollowSeconds)},
+		},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			notifySID := sessionIDFromContext(ctx)
+			if notifySID == "" {
+				return mcp.NewToolResultError("当前连接没有会话，无法推送日志"), nil
+			}
+			timeout := args.Int("timeout_seconds", defaultFollowSeconds)
+			if timeout <= 0 {
+				return mcp.NewToolResultError("timeout_seconds 必须大于 0"), nil
+			}
+			if timeout > maxFollowSeconds {
+				timeout = maxFollowSeconds
+			}
+			// 日志流的生命周期独立于本次工具调用
+			followCtx, cancel := context.WithCancelCause(context.Background())
+			followCtx, cancelTimeout := context.WithTimeoutCause(followCtx, time.Duration(timeout)*time.Second, errFollowTimeout)
+			f := &logFollow{
+				ID:          uuid.NewString(),
+				NotifySID:   notifySID,
+				AppSID:      appSessionIDFromContext(ctx),
+				ClusterName: args.String("cluster_name"),
+				Namespace:   args.String("namespace"),
+				Pod:         args.String("name"),
+				startedAt:   time.Now(),
+				cancel: func(cause error) {
+					cancel(cause)
+					cancelTimeout()
+				},
+			}
+			if err := s.addLogFollow(f); err != nil {
+				f.cancel(err)
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			opts := tools.PodLogOptions{
+				Container:  args.String("container"),
+				TailLines:  int64(args.Int("tail_lines", 0)),
+				Timestamps: args.Bool("timestamps", false),
+			}
+			go s.runLogFollow(followCtx, f, opts)
+			return jsonResult(map[string]any{
+				"follow_id":           f.ID,
+				"notification_method": podLogsNotificationMethod,
+				"timeout_seconds":     timeout,
+			})
+		},
+	})
+	// stop_pod_logs
+	s.registerTool(toolSpec{
+		Name:        "stop_pod_logs",
+		Description: "Stop a log follow started by follow_pod_logs in this session",
+		Method:      "POST",
+		Path:        "/stop_pod_logs",
+		Params: []toolParam{
+			{Name: "follow_id", Type: paramString, Required: true, Description: "follow_pod_logs 返回的 follow_id"},
+		},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			if !s.stopLogFollow(args.String("follow_id"), sessionIDFromContext(ctx)) {
+				return mcp.NewToolResultError("follow_id 不存在或不属于当前会话"), nil
+			}
+			return mcp.NewToolResultText("日志 follow 已停止"), nil
+		},
+	})
+}
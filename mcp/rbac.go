@@ -0,0 +1,274 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/relaxyabc/k8s-helper/dao"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// RolePolicy 单个角色的授权规则
+// Tools/Clusters/Namespaces 均支持 glob 通配（path.Match 语法），未列出即不允许
+type RolePolicy struct {
+	Tools      []string `json:"tools"`
+	Clusters   []string `json:"clusters"`
+	Namespaces []string `json:"namespaces"`
+}
+
+// ProtectedNamespace 受保护命名空间规则，scale_workload 不允许缩容到 MinReplicas 以下
+// Clusters/Namespaces 支持 glob 通配，Clusters 为空表示所有集群
+type ProtectedNamespace struct {
+	Clusters    []string `json:"clusters"`
+	Namespaces  []string `json:"namespaces"`
+	MinReplicas int32    `json:"min_replicas"`
+}
+
+// RBACPolicy 角色授权策略，角色名 -> 规则
+// 工具列表过滤与工具调用鉴权共用同一份策略，保证两者结论一致
+type RBACPolicy struct {
+	Roles               map[string]RolePolicy `json:"roles"`
+	ProtectedNamespaces []ProtectedNamespace  `json:"protected_namespaces"`
+}
+
+// defaultRBACPolicy 未指定策略文件时使用的内置策略
+var defaultRBACPolicy = &RBACPolicy{
+	Roles: map[string]RolePolicy{
+		"admin": {
+			Tools:      []string{"*"},
+			Clusters:   []string{"*"},
+			Namespaces: []string{"*"},
+		},
+		"user": {
+			Tools:      []string{"get_clusters", "get_pods", "get_deployments", "get_daemonsets", "use_cluster", "use_namespace"},
+			Clusters:   []string{"*"},
+			Namespaces: []string{"*"},
+		},
+		"guest": {
+			Tools:      []string{"get_clusters"},
+			Clusters:   []string{"*"},
+			Namespaces: []string{"*"},
+		},
+	},
+}
+
+// rbacPolicy 当前生效的策略，启动时通过 LoadRBACPolicyFile 替换
+var rbacPolicy = defaultRBACPolicy
+
+// ParseRBACPolicy 解析 YAML/JSON 格式的策略内容，并校验通配表达式
+func ParseRBACPolicy(data []byte) (*RBACPolicy, error) {
+	var p RBACPolicy
+	if err := yaml.UnmarshalStrict(data, &p); err != nil {
+		return nil, fmt.Errorf("解析策略失败: %w", err)
+	}
+	if len(p.Roles) == 0 {
+		return nil, fmt.Errorf("策略未定义任何角色")
+	}
+	for role, rp := range p.Roles {
+		for _, patterns := range [][]string{rp.Tools, rp.Clusters, rp.Namespaces} {
+			for _, pattern := range patterns {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return nil, fmt.Errorf("角色 %s 的通配表达式 %q 非法: %w", role, pattern, err)
+				}
+			}
+		}
+	}
+	for i, pn := range p.ProtectedNamespaces {
+		if pn.MinReplicas < 0 {
+			return nil, fmt.Errorf("protected_namespaces[%d] 的 min_replicas 不能为负数", i)
+		}
+		for _, patterns := range [][]string{pn.Clusters, pn.Namespaces} {
+			for _, pattern := range patterns {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return nil, fmt.Errorf("protected_namespaces[%d] 的通配表达式 %q 非法: %w", i, pattern, err)
+				}
+			}
+		}
+	}
+	return &p, nil
+}
+
+// SetRBACPolicy 设置当前生效策略，返回之前的策略
+func SetRBACPolicy(p *RBACPolicy) *RBACPolicy {
+	prev := rbacPolicy
+	rbacPolicy = p
+	return prev
+}
+
+// LoadRBACPolicyFile 从文件加载策略并设置为当前生效策略
+func LoadRBACPolicyFile(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("读取策略文件失败: %w", err)
+	}
+	p, err := ParseRBACPolicy(data)
+	if err != nil {
+		return err
+	}
+	SetRBACPolicy(p)
+	klog.Infof("[RBAC] Loaded policy from %s, roles=%d", file, len(p.Roles))
+	return nil
+}
+
+// matchAny 判断 value 是否匹配任一通配表达式
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowTool 判断角色是否允许使用指定工具
+func (p *RBACPolicy) AllowTool(role, toolName string) bool {
+	rp, ok := p.Roles[role]
+	return ok && matchAny(rp.Tools, toolName)
+}
+
+// AllowCluster 判断角色是否允许访问指定集群（匹配 ClusterInfo.ClusterName）
+func (p *RBACPolicy) AllowCluster(role, clusterName string) bool {
+	rp, ok := p.Roles[role]
+	return ok && matchAny(rp.Clusters, clusterName)
+}
+
+// AllowNamespace 判断角色是否允许访问指定命名空间
+func (p *RBACPolicy) AllowNamespace(role, namespace string) bool {
+	rp, ok := p.Roles[role]
+	return ok && matchAny(rp.Namespaces, namespace)
+}
+
+// MinReplicas 返回集群命名空间允许的最小副本数，命中多条规则时取最大值，未命中返回 0
+func (p *RBACPolicy) MinReplicas(clusterName, namespace string) int32 {
+	var minReplicas int32
+	for _, pn := range p.ProtectedNamespaces {
+		if len(pn.Clusters) > 0 && !matchAny(pn.Clusters, clusterName) {
+			continue
+		}
+		if matchAny(pn.Namespaces, namespace) && pn.MinReplicas > minReplicas {
+			minReplicas = pn.MinReplicas
+		}
+	}
+	return minReplicas
+}
+
+// IsToolAllowed 按当前生效策略判断角色是否允许使用指定工具
+func IsToolAllowed(role, toolName string) bool {
+	return rbacPolicy.AllowTool(role, toolName)
+}
+
+// sessionIDFromContext 从 MCP 请求上下文中获取 sessionId
+func sessionIDFromContext(ctx context.Context) string {
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return session.SessionID()
+	}
+	return ""
+}
+
+// scopeError 调用方无权访问集群或命名空间
+type scopeError string
+
+func (e scopeError) Error() string {
+	return string(e)
+}
+
+// checkScope 校验角色是否有权访问指定集群和命名空间，namespace 为空时仅校验集群，拒绝时返回 scopeError
+func checkScope(role, clusterName, namespace string) error {
+	if !rbacPolicy.AllowCluster(role, clusterName) {
+		return scopeError(fmt.Sprintf("无权访问集群 %s（角色: %s）", clusterName, role))
+	}
+	if namespace != "" && !rbacPolicy.AllowNamespace(role, namespace) {
+		return scopeError(fmt.Sprintf("无权访问集群 %s 的命名空间 %s（角色: %s）", clusterName, namespace, role))
+	}
+	return nil
+}
+
+// authorizeScope 校验调用方是否有权访问指定集群和命名空间，namespace 为空时仅校验集群
+func (s *MCPServer) authorizeScope(ctx context.Context, clusterName, namespace string) error {
+	sid := sessionIDFromContext(ctx)
+	caller := s.identity(sid)
+	if err := checkScope(caller.Role, clusterName, namespace); err != nil {
+		klog.Warningf("[AUTHZ] denied: sid=%s, user=%s, role=%s, cluster=%s, namespace=%s", sid, caller.UserID, caller.Role, clusterName, namespace)
+		return err
+	}
+	return nil
+}
+
+// filterClustersByRole 过滤出调用方有权访问的集群
+func (s *MCPServer) filterClustersByRole(ctx context.Context, clusters []dao.ClusterInfo) []dao.ClusterInfo {
+	role := s.identity(sessionIDFromContext(ctx)).Role
+	filtered := []dao.ClusterInfo{}
+	for _, c := range clusters {
+		if rbacPolicy.AllowCluster(role, c.ClusterName) {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered
+}
+
+// filterNamespacesByRole 过滤出调用方有权访问的命名空间
+func (s *MCPServer) filterNamespacesByRole(ctx context.Context, namespaces []string) []string {
+	role := s.identity(sessionIDFromContext(ctx)).Role
+	filtered := []string{}
+	for _, ns := range namespaces {
+		if rbacPolicy.AllowNamespace(role, ns) {
+			filtered = append(filtered, ns)
+		}
+	}
+	return filtered
+}
+
+// filterResourcesByRole 过滤掉跨命名空间查询结果中调用方无权访问的命名空间下的资源，集群级资源保留
+func (s *MCPServer) filterResourcesByRole(ctx context.Context, list *unstructured.UnstructuredList) {
+	role := s.identity(sessionIDFromContext(ctx)).Role
+	items := list.Items[:0]
+	for _, item := range list.Items {
+		if ns := item.GetNamespace(); ns == "" || rbacPolicy.AllowNamespace(role, ns) {
+			items = append(items, item)
+		}
+	}
+	list.Items = items
+}
+
+// filterToolsByRole 按调用方角色过滤 tools/list 返回的工具
+func (s *MCPServer) filterToolsByRole(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+	sid := sessionIDFromContext(ctx)
+	role := s.identity(sid).Role
+	var toolNames []string
+	for _, t := range tools {
+		toolNames = append(toolNames, t.Name)
+	}
+	klog.Infof("[TOOL_FILTER] sid=%s, role=%s, all_tools=%v", sid, role, toolNames)
+	var filtered []mcp.Tool
+	var filteredNames []string
+	for _, tool := range tools {
+		if IsToolAllowed(role, tool.Name) {
+			filtered = append(filtered, tool)
+			filteredNames = append(filteredNames, tool.Name)
+		}
+	}
+	klog.Infof("[TOOL_FILTER] sid=%s, role=%s, filtered_tools=%v", sid, role, filteredNames)
+	return filtered
+}
+
+// authorizeToolCall 工具调用鉴权中间件，拒绝角色无权使用的 tools/call 请求
+func (s *MCPServer) authorizeToolCall(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		toolName := request.Params.Name
+		sid := sessionIDFromContext(ctx)
+		caller := s.identity(sid)
+		role := caller.Role
+		if !IsToolAllowed(role, toolName) {
+			klog.Warningf("[AUTHZ] denied: sid=%s, user=%s, role=%s, tool=%s", sid, caller.UserID, role, toolName)
+			return mcp.NewToolResultError(fmt.Sprintf("无权调用工具 %s（角色: %s）", toolName, role)), nil
+		}
+		return next(ctx, request)
+	}
+}
@@ -2,14 +2,11 @@ package mcp
 
 import (
 	"context"
-	"encoding/json"
 	"strconv"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/relaxyabc/k8s-helper/common"
-	"github.com/relaxyabc/k8s-helper/dao"
-	"github.com/relaxyabc/k8s-helper/tools"
 
 	"github.com/google/uuid"
 	"github.com/mark3labs/mcp-go/mcp"
@@ -33,369 +30,69 @@ func GetTransport() string {
 }
 
 type MCPServer struct {
-	server *server.MCPServer
+	server          *server.MCPServer
+	mutatingTools   map[string]toolSpec // 变更类工具，审批通过后按名称查找并执行
+	contextTools    map[string]toolSpec // 参数可取自会话上下文的工具，审计时按名称补全集群和命名空间
+	sessions        *HTTPSessionManager // http/sse 模式的会话管理器，stdio 模式为 nil
+	defaultIdentity Identity            // 没有会话管理器时（stdio 模式）的调用方身份
+	streams         map[string]string   // 可接收通知的 mcp-go 会话 ID -> 所属应用会话 ID
+	streamsMutex    sync.Mutex
+	logFollows      map[string]*logFollow // 进行中的日志 follow，key 为 follow_id
+	logFollowsMutex sync.Mutex
 }
 
 func NewMCPServer(opts ...server.ServerOption) *MCPServer {
+	s := &MCPServer{
+		mutatingTools: make(map[string]toolSpec),
+		contextTools:  make(map[string]toolSpec),
+		streams:       make(map[string]string),
+		logFollows:    make(map[string]*logFollow),
+	}
+	// SSE 连接和 HTTP GET 监听流注册时登记，用于向应用会话推送异步通知（如审批结果）
+	hooks := &server.Hooks{}
+	hooks.AddOnRegisterSession(s.trackStream)
+	hooks.AddOnUnregisterSession(s.untrackStream)
 	defaultOpts := []server.ServerOption{
 		server.WithToolCapabilities(true),
+		server.WithHooks(hooks),
+		// 每次调用只读取一次会话，后续中间件和工具从 ctx 取当前上下文
+		server.WithToolHandlerMiddleware(s.loadSession),
+		// 当前上下文附加在审计之外，审计记录的结果不含上下文说明
+		server.WithToolHandlerMiddleware(s.appendContext),
+		// 审计放在外层，记录包括 panic 和鉴权拒绝在内的所有调用
+		server.WithToolHandlerMiddleware(s.auditToolCall),
 		server.WithRecovery(),
-		server.WithToolFilter(func(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
-			role := ""
-			sid := ""
-			if session := server.ClientSessionFromContext(ctx); session != nil {
-				sid = session.SessionID()
-				role = GetUserRoleBySessionID(sid)
-			}
-			var toolNames []string
-			for _, t := range tools {
-				toolNames = append(toolNames, t.Name)
-			}
-			klog.Infof("[TOOL_FILTER] sid=%s, role=%s, all_tools=%v", sid, role, toolNames)
-			if role == "admin" {
-				return tools // admin 全部
-			}
-			var filtered []mcp.Tool
-			for _, tool := range tools {
-				if role == "user" {
-					// user 仅允许 get_clusters、get_pods、get_deployments、get_daemonsets
-					if tool.Name == "get_clusters" || tool.Name == "get_pods" || tool.Name == "get_deployments" || tool.Name == "get_daemonsets" {
-						filtered = append(filtered, tool)
-					}
-				} else if role == "guest" {
-					if tool.Name == "get_clusters" {
-						filtered = append(filtered, tool)
-					}
-				}
-			}
-			klog.Infof("[TOOL_FILTER] sid=%s, role=%s, filtered_tools=%v", sid, role, func() []string {
-				names := []string{}
-				for _, t := range filtered {
-					names = append(names, t.Name)
-				}
-				return names
-			}())
-			return filtered
-		}),
+		server.WithToolFilter(s.filterToolsByRole),
+		server.WithToolHandlerMiddleware(s.authorizeToolCall),
 	}
 	allOpts := append(defaultOpts, opts...)
-	mcpServer := server.NewMCPServer(
+	s.server = server.NewMCPServer(
 		"k8s-helper",
 		"1.0.0",
 		allOpts...,
 	)
 
-	// 工具注册（全部 http tool 风格）
-	registerHTTPTool := func(toolName, desc string, handler func(ctx context.Context, method, url, body string) (*mcp.CallToolResult, error)) {
-		t := mcp.NewTool(toolName,
-			mcp.WithDescription(desc),
-			mcp.WithString("method", mcp.Required(), mcp.Description("HTTP method: GET/POST/PUT/DELETE"), mcp.Enum("GET", "POST", "PUT", "DELETE")),
-			mcp.WithString("url", mcp.Required(), mcp.Description("API 路径，如 /clusters /namespaces?cluster_name=xxx 等")),
-			mcp.WithString("body", mcp.Description("请求体（POST/PUT 时可选)")),
-		)
-		mcpServer.AddTool(t, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-			args := request.GetArguments()
-			method, _ := args["method"].(string)
-			url, _ := args["url"].(string)
-			body := ""
-			if b, ok := args["body"].(string); ok {
-				body = b
-			}
-			sid := ""
-			if session := server.ClientSessionFromContext(ctx); session != nil {
-				sid = session.SessionID()
-			}
-			paramsJson, _ := json.Marshal(args)
-			klog.Infof("[%s][%s][sessionid:%s]-%s-%s", time.Now().Format("2006-01-02 15:04:05"), transport, sid, toolName, string(paramsJson))
-			return handler(ctx, method, url, body)
-		})
-	}
+	s.registerK8sTools()
+	s.registerApprovalTools()
+	s.registerAuditTools()
+	s.registerClusterAdminTools()
+	s.registerSessionTools()
+	s.registerContextTools()
+	return s
+}
 
-	// get_clusters
-	registerHTTPTool("get_clusters", "Get all clusters from database (HTTP tool 风格)", func(ctx context.Context, method, url, body string) (*mcp.CallToolResult, error) {
-		if method != "GET" || url != "/clusters" {
-			return mcp.NewToolResultError("仅支持 GET /clusters"), nil
-		}
-		result, err := dao.GetClusterInfos()
-		if err != nil {
-			return mcp.NewToolResultError("查询数据库失败: " + err.Error()), nil
-		}
-		jsonStr, err := json.Marshal(result)
-		if err != nil {
-			return mcp.NewToolResultError("序列化失败: " + err.Error()), nil
-		}
-		return mcp.NewToolResultText(string(jsonStr)), nil
-	})
-	// get_namespaces
-	registerHTTPTool("get_namespaces", "Get namespaces list for a cluster (HTTP tool 风格)", func(ctx context.Context, method, url, body string) (*mcp.CallToolResult, error) {
-		if method != "GET" || !strings.HasPrefix(url, "/namespaces") {
-			return mcp.NewToolResultError("仅支持 GET /namespaces?cluster_name=xxx"), nil
-		}
-		q := url[strings.Index(url, "?")+1:]
-		params := make(map[string]string)
-		for _, kv := range strings.Split(q, "&") {
-			if kv == "" {
-				continue
-			}
-			arr := strings.SplitN(kv, "=", 2)
-			if len(arr) == 2 {
-				params[arr[0]] = arr[1]
-			}
-		}
-		clusterName := params["cluster_name"]
-		if clusterName == "" {
-			return mcp.NewToolResultError("参数 cluster_name 必填"), nil
-		}
-		nsList, err := tools.GetNamespacesTool(proxy, clusterName)
-		if err != nil {
-			return mcp.NewToolResultError("获取 namespace 失败: " + err.Error()), nil
-		}
-		jsonStr, err := json.Marshal(nsList)
-		if err != nil {
-			return mcp.NewToolResultError("序列化失败: " + err.Error()), nil
-		}
-		return mcp.NewToolResultText(string(jsonStr)), nil
-	})
-	// get_pods
-	registerHTTPTool("get_pods", "Get pods in a namespace for a cluster (HTTP tool 风格)", func(ctx context.Context, method, url, body string) (*mcp.CallToolResult, error) {
-		if method != "GET" || !strings.HasPrefix(url, "/pods") {
-			return mcp.NewToolResultError("仅支持 GET /pods?cluster_name=xxx&namespace=xxx"), nil
-		}
-		q := url[strings.Index(url, "?")+1:]
-		params := make(map[string]string)
-		for _, kv := range strings.Split(q, "&") {
-			if kv == "" {
-				continue
-			}
-			arr := strings.SplitN(kv, "=", 2)
-			if len(arr) == 2 {
-				params[arr[0]] = arr[1]
-			}
-		}
-		clusterName := params["cluster_name"]
-		namespace := params["namespace"]
-		if clusterName == "" || namespace == "" {
-			return mcp.NewToolResultError("参数 cluster_name 和 namespace 必填"), nil
-		}
-		pods, err := tools.GetPodsTool(proxy, clusterName, namespace)
-		if err != nil {
-			return mcp.NewToolResultError("获取 pods 失败: " + err.Error()), nil
-		}
-		jsonStr, err := json.Marshal(pods)
-		if err != nil {
-			return mcp.NewToolResultError("序列化失败: " + err.Error()), nil
-		}
-		return mcp.NewToolResultText(string(jsonStr)), nil
-	})
-	// get_deployments
-	registerHTTPTool("get_deployments", "Get deployments in a namespace for a cluster (HTTP tool 风格)", func(ctx context.Context, method, url, body string) (*mcp.CallToolResult, error) {
-		if method != "GET" || !strings.HasPrefix(url, "/deployments") {
-			return mcp.NewToolResultError("仅支持 GET /deployments?cluster_name=xxx&namespace=xxx"), nil
-		}
-		q := url[strings.Index(url, "?")+1:]
-		params := make(map[string]string)
-		for _, kv := range strings.Split(q, "&") {
-			if kv == "" {
-				continue
-			}
-			arr := strings.SplitN(kv, "=", 2)
-			if len(arr) == 2 {
-				params[arr[0]] = arr[1]
-			}
-		}
-		clusterName := params["cluster_name"]
-		namespace := params["namespace"]
-		if clusterName == "" || namespace == "" {
-			return mcp.NewToolResultError("参数 cluster_name 和 namespace 必填"), nil
-		}
-		deployments, err := tools.GetDeploymentsTool(proxy, clusterName, namespace)
-		if err != nil {
-			return mcp.NewToolResultError("获取 deployments 失败: " + err.Error()), nil
-		}
-		jsonStr, err := json.Marshal(deployments)
-		if err != nil {
-			return mcp.NewToolResultError("序列化失败: " + err.Error()), nil
-		}
-		return mcp.NewToolResultText(string(jsonStr)), nil
-	})
-	// get_daemonsets
-	registerHTTPTool("get_daemonsets", "Get daemonsets in a namespace for a cluster (HTTP tool 风格)", func(ctx context.Context, method, url, body string) (*mcp.CallToolResult, error) {
-		if method != "GET" || !strings.HasPrefix(url, "/daemonsets") {
-			return mcp.NewToolResultError("仅支持 GET /daemonsets?cluster_name=xxx&namespace=xxx"), nil
-		}
-		q := url[strings.Index(url, "?")+1:]
-		params := make(map[string]string)
-		for _, kv := range strings.Split(q, "&") {
-			if kv == "" {
-				continue
-			}
-			arr := strings.SplitN(kv, "=", 2)
-			if len(arr) == 2 {
-				params[arr[0]] = arr[1]
-			}
-		}
-		clusterName := params["cluster_name"]
-		namespace := params["namespace"]
-		if clusterName == "" || namespace == "" {
-			return mcp.NewToolResultError("参数 cluster_name 和 namespace 必填"), nil
-		}
-		daemonsets, err := tools.GetDaemonSetsTool(proxy, clusterName, namespace)
-		if err != nil {
-			return mcp.NewToolResultError("获取 daemonsets 失败: " + err.Error()), nil
-		}
-		jsonStr, err := json.Marshal(daemonsets)
-		if err != nil {
-			return mcp.NewToolResultError("序列化失败: " + err.Error()), nil
-		}
-		return mcp.NewToolResultText(string(jsonStr)), nil
-	})
-	// rollout_restart_deployment
-	registerHTTPTool("rollout_restart_deployment", "滚动重启指定 Deployment (HTTP tool 风格)", func(ctx context.Context, method, url, body string) (*mcp.CallToolResult, error) {
-		if method != "POST" || !strings.HasPrefix(url, "/rollout_restart_deployment") {
-			return mcp.NewToolResultError("仅支持 POST /rollout_restart_deployment?cluster_name=xxx&namespace=xxx&name=xxx"), nil
-		}
-		q := url[strings.Index(url, "?")+1:]
-		params := make(map[string]string)
-		for _, kv := range strings.Split(q, "&") {
-			if kv == "" {
-				continue
-			}
-			arr := strings.SplitN(kv, "=", 2)
-			if len(arr) == 2 {
-				params[arr[0]] = arr[1]
-			}
-		}
-		clusterName := params["cluster_name"]
-		namespace := params["namespace"]
-		name := params["name"]
-		if clusterName == "" || namespace == "" || name == "" {
-			return mcp.NewToolResultError("参数 cluster_name、namespace、name 必填"), nil
-		}
-		err := tools.RolloutRestartDeploymentTool(proxy, clusterName, namespace, name)
-		if err != nil {
-			return mcp.NewToolResultError("滚动重启 Deployment 失败: " + err.Error()), nil
-		}
-		return mcp.NewToolResultText("Deployment rollout restarted successfully."), nil
-	})
-	// rollout_restart_daemonset
-	registerHTTPTool("rollout_restart_daemonset", "滚动重启指定 DaemonSet (HTTP tool 风格)", func(ctx context.Context, method, url, body string) (*mcp.CallToolResult, error) {
-		if method != "POST" || !strings.HasPrefix(url, "/rollout_restart_daemonset") {
-			return mcp.NewToolResultError("仅支持 POST /rollout_restart_daemonset?cluster_name=xxx&namespace=xxx&name=xxx"), nil
-		}
-		q := url[strings.Index(url, "?")+1:]
-		params := make(map[string]string)
-		for _, kv := range strings.Split(q, "&") {
-			if kv == "" {
-				continue
-			}
-			arr := strings.SplitN(kv, "=", 2)
-			if len(arr) == 2 {
-				params[arr[0]] = arr[1]
-			}
-		}
-		clusterName := params["cluster_name"]
-		namespace := params["namespace"]
-		name := params["name"]
-		if clusterName == "" || namespace == "" || name == "" {
-			return mcp.NewToolResultError("参数 cluster_name、namespace、name 必填"), nil
-		}
-		err := tools.RolloutRestartDaemonSetTool(proxy, clusterName, namespace, name)
-		if err != nil {
-			return mcp.NewToolResultError("滚动重启 DaemonSet 失败: " + err.Error()), nil
-		}
-		return mcp.NewToolResultText("DaemonSet 滚动重启成功"), nil
-	})
-	// get_k8s_version
-	registerHTTPTool("get_k8s_version", "Get k8s version for a cluster (HTTP tool 风格)", func(ctx context.Context, method, url, body string) (*mcp.CallToolResult, error) {
-		if method != "GET" || !strings.HasPrefix(url, "/k8s_version") {
-			return mcp.NewToolResultError("仅支持 GET /k8s_version?cluster_name=xxx"), nil
-		}
-		q := url[strings.Index(url, "?")+1:]
-		params := make(map[string]string)
-		for _, kv := range strings.Split(q, "&") {
-			if kv == "" {
-				continue
-			}
-			arr := strings.SplitN(kv, "=", 2)
-			if len(arr) == 2 {
-				params[arr[0]] = arr[1]
-			}
-		}
-		clusterName := params["cluster_name"]
-		if clusterName == "" {
-			return mcp.NewToolResultError("参数 cluster_name 必填"), nil
-		}
-		version, err := tools.GetK8sVersionTool(proxy, clusterName)
-		if err != nil {
-			return mcp.NewToolResultError("获取 k8s 版本失败: " + err.Error()), nil
-		}
-		return mcp.NewToolResultText(version), nil
-	})
-	// get_configmaps
-	registerHTTPTool("get_configmaps", "Get configmaps in a namespace for a cluster (HTTP tool 风格)", func(ctx context.Context, method, url, body string) (*mcp.CallToolResult, error) {
-		if method != "GET" || !strings.HasPrefix(url, "/configmaps") {
-			return mcp.NewToolResultError("仅支持 GET /configmaps?cluster_name=xxx&namespace=xxx"), nil
-		}
-		q := url[strings.Index(url, "?")+1:]
-		params := make(map[string]string)
-		for _, kv := range strings.Split(q, "&") {
-			if kv == "" {
-				continue
-			}
-			arr := strings.SplitN(kv, "=", 2)
-			if len(arr) == 2 {
-				params[arr[0]] = arr[1]
-			}
-		}
-		clusterName := params["cluster_name"]
-		namespace := params["namespace"]
-		if clusterName == "" || namespace == "" {
-			return mcp.NewToolResultError("参数 cluster_name 和 namespace 必填"), nil
-		}
-		configmaps, err := tools.GetConfigMapsTool(proxy, clusterName, namespace)
-		if err != nil {
-			return mcp.NewToolResultError("获取 configmaps 失败: " + err.Error()), nil
-		}
-		jsonStr, err := json.Marshal(configmaps)
-		if err != nil {
-			return mcp.NewToolResultError("序列化失败: " + err.Error()), nil
-		}
-		return mcp.NewToolResultText(string(jsonStr)), nil
-	})
-	// configmap_detail
-	registerHTTPTool("configmap_detail", "Get detail of a configmap in a namespace for a cluster (HTTP tool 风格)", func(ctx context.Context, method, url, body string) (*mcp.CallToolResult, error) {
-		if method != "GET" || !strings.HasPrefix(url, "/configmap_detail") {
-			return mcp.NewToolResultError("仅支持 GET /configmap_detail?cluster_name=xxx&namespace=xxx&name=xxx"), nil
-		}
-		q := url[strings.Index(url, "?")+1:]
-		params := make(map[string]string)
-		for _, kv := range strings.Split(q, "&") {
-			if kv == "" {
-				continue
-			}
-			arr := strings.SplitN(kv, "=", 2)
-			if len(arr) == 2 {
-				params[arr[0]] = arr[1]
-			}
-		}
-		clusterName := params["cluster_name"]
-		namespace := params["namespace"]
-		name := params["name"]
-		if clusterName == "" || namespace == "" || name == "" {
-			return mcp.NewToolResultError("参数 cluster_name、namespace、name 必填"), nil
-		}
-		data, err := tools.GetConfigMapDetailTool(proxy, clusterName, namespace, name)
-		if err != nil {
-			return mcp.NewToolResultError("获取 configmap 详情失败: " + err.Error()), nil
-		}
-		jsonStr, err := json.Marshal(data)
-		if err != nil {
-			return mcp.NewToolResultError("序列化失败: " + err.Error()), nil
-		}
-		return mcp.NewToolResultText(string(jsonStr)), nil
-	})
+// SetDefaultIdentity 设置没有会话管理器时（stdio 模式）的调用方身份
+func (s *MCPServer) SetDefaultIdentity(id Identity) {
+	s.defaultIdentity = id
+}
 
-	return &MCPServer{server: mcpServer}
+// identity 返回会话对应的调用方身份，未知会话返回空身份
+func (s *MCPServer) identity(sessionID string) Identity {
+	if s.sessions == nil {
+		return s.defaultIdentity
+	}
+	id, _ := s.sessions.Identity(sessionID)
+	return id
 }
 
 func (s *MCPServer) ServeHTTP() *server.StreamableHTTPServer {
@@ -411,6 +108,7 @@ func generateSessionID() string {
 }
 
 func (s *MCPServer) RegisterSSEPushTool(sseServer *SSEServer) {
+	s.registerLogFollowTools()
 	s.server.AddTool(
 		mcp.NewTool("start_sse_push",
 			mcp.WithDescription("Starts a background task that pushes notifications to the client via SSE."),
@@ -446,57 +144,62 @@ func (s *MCPServer) RegisterSSEPushTool(sseServer *SSEServer) {
 	)
 }
 
-// RegisterSession 注册 session 到 MCP 服务器，确保 sessionId 同步
-func (s *MCPServer) RegisterSession(sessionID string) {
-	klog.Infof("[MCP-SERVER] Registering session: %s", sessionID)
+// trackStream 登记可接收通知的 mcp-go 会话（SSE 连接或 HTTP GET 监听流）及其所属的应用会话
+func (s *MCPServer) trackStream(ctx context.Context, session server.ClientSession) {
+	appSID, _ := ctx.Value(common.ContextKeyMcpSession).(string)
+	if appSID == "" {
+		appSID = session.SessionID()
+	}
+	s.streamsMutex.Lock()
+	defer s.streamsMutex.Unlock()
+	s.streams[session.SessionID()] = appSID
+	klog.Infof("[MCP-SERVER] notification stream %s registered for session %s", session.SessionID(), appSID)
+}
+
+// untrackStream 注销通知流
+func (s *MCPServer) untrackStream(ctx context.Context, session server.ClientSession) {
+	s.streamsMutex.Lock()
+	defer s.streamsMutex.Unlock()
+	delete(s.streams, session.SessionID())
+}
 
-	// 创建一个简单的 ClientSession 实现
-	session := &simpleClientSession{
-		sessionID:   sessionID,
-		notifyChan:  make(chan mcp.JSONRPCNotification, 10), // 缓冲通道
-		initialized: true,
+// notifySession 向会话推送通知：sessionID 可以是 mcp-go 会话 ID 或应用会话 ID，
+// 推送到该会话本身及同一应用会话的全部通知流，返回成功推送的流数量
+func (s *MCPServer) notifySession(sessionID, method string, params map[string]any) int {
+	s.streamsMutex.Lock()
+	appSID, ok := s.streams[sessionID]
+	if !ok {
+		appSID = sessionID
+	}
+	var targets []string
+	for streamID, owner := range s.streams {
+		if streamID == sessionID || owner == appSID {
+			targets = append(targets, streamID)
+		}
 	}
+	s.streamsMutex.Unlock()
 
-	// 调用底层的 MCP 库 RegisterSession 函数
-	ctx := context.Background()
-	err := s.server.RegisterSession(ctx, session)
-	if err != nil {
-		klog.Errorf("[MCP-SERVER] Failed to register session %s: %v", sessionID, err)
-	} else {
-		klog.Infof("[MCP-SERVER] Successfully registered session: %s", sessionID)
+	sent := 0
+	for _, streamID := range targets {
+		if err := s.server.SendNotificationToSpecificClient(streamID, method, params); err != nil {
+			klog.Warningf("[MCP-SERVER] notify stream %s of session %s failed: %v", streamID, sessionID, err)
+			continue
+		}
+		sent++
 	}
+	return sent
 }
 
 // UnregisterSession 从 MCP 服务器注销 session
 func (s *MCPServer) UnregisterSession(sessionID string) {
 	klog.Infof("[MCP-SERVER] Unregistering session: %s", sessionID)
 
+	// 会话结束时停止其日志 follow
+	s.stopSessionLogFollows(sessionID)
+
 	// 调用底层的 MCP 库 UnregisterSession 函数
 	ctx := context.Background()
 	s.server.UnregisterSession(ctx, sessionID)
 
 	klog.Infof("[MCP-SERVER] Successfully unregistered session: %s", sessionID)
 }
-
-// simpleClientSession 实现 ClientSession 接口
-type simpleClientSession struct {
-	sessionID   string
-	notifyChan  chan mcp.JSONRPCNotification
-	initialized bool
-}
-
-func (s *simpleClientSession) Initialize() {
-	s.initialized = true
-}
-
-func (s *simpleClientSession) Initialized() bool {
-	return s.initialized
-}
-
-func (s *simpleClientSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
-	return s.notifyChan
-}
-
-func (s *simpleClientSession) SessionID() string {
-	return s.sessionID
-}
@@ -3,6 +3,9 @@ package mcp
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
 	"net/http"
 	"net/url"
 	"strings"
@@ -14,183 +17,420 @@ import (
 	"k8s.io/klog/v2"
 )
 
-var AESKey = "k8s-mcp-client" // 默认 AES 加密 key
+var AESKey = "k8s-mcp-client" // 旧版 mcpId 的 AES 加密 key
 
-// 是否允许同一用户多 session，默认 false
-var AllowMultiSession = false
+// TokenKeys mcpId 令牌密钥，为 nil 时不接受新格式令牌
+var TokenKeys *crypto.KeyRing
 
-// 优化版 HTTPSessionManager
+// AllowLegacyMcpID 是否兼容旧版 AES-CBC 格式的 mcpId（已废弃），默认 false
+var AllowLegacyMcpID = false
 
+// 同一用户的多会话策略
+const (
+	SessionPolicyShare = "share" // 同一用户的所有连接共用一个会话
+	SessionPolicyLimit = "limit" // 每个用户最多 MaxPerUser 个会话，超出时拒绝新会话
+	SessionPolicyEvict = "evict" // 每个用户最多 MaxPerUser 个会话，超出时淘汰最早创建的会话
+)
+
+// ErrSessionLimit 用户会话数已达上限
+var ErrSessionLimit = errors.New("too many sessions")
+
+// SessionPolicy 同一用户的多会话策略
+type SessionPolicy struct {
+	Mode       string
+	MaxPerUser int // limit/evict 模式下每个用户的会话数上限
+}
+
+// Validate 校验策略配置
+func (p SessionPolicy) Validate() error {
+	switch p.Mode {
+	case SessionPolicyShare:
+		return nil
+	case SessionPolicyLimit, SessionPolicyEvict:
+		if p.MaxPerUser < 1 {
+			return fmt.Errorf("max sessions per user must be at least 1, got %d", p.MaxPerUser)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown session policy %q, want %s, %s or %s", p.Mode, SessionPolicyShare, SessionPolicyLimit, SessionPolicyEvict)
+	}
+}
+
+// HTTPSessionManager 管理 HTTP/SSE 会话，会话保存在 SessionStore 中，多副本共享同一存储时可互相识别会话
 type HTTPSessionManager struct {
-	sessions   map[string]*HTTPSession
-	mutex      sync.RWMutex
-	cleanup    *time.Ticker
-	expireTime time.Duration
+	store        SessionStore
+	server       *MCPServer          // 会话失效时从中注销，可为 nil
+	policy       SessionPolicy       // 同一用户的多会话策略
+	identities   map[string]Identity // 本副本处理过的会话及其身份，过期或被删除时需注销
+	mutex        sync.Mutex          // 保护 policy 和 identities，持有期间不访问会话存储
+	sessionLocks keyedMutex          // 同一会话的读改写串行执行
+	userLocks    keyedMutex          // 同一用户的会话创建串行执行，保证多会话策略生效
+	cleanup      *time.Ticker
+	expireTime   time.Duration
+}
+
+// keyedMutex 按 key 加锁，同一 key 的操作串行执行，不同 key 互不阻塞
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	refs int
+}
+
+// Lock 锁定 key，返回解锁函数
+func (k *keyedMutex) Lock(key string) func() {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = make(map[string]*keyedLock)
+	}
+	l := k.locks[key]
+	if l == nil {
+		l = &keyedLock{}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		k.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
 }
 
 type HTTPSession struct {
 	ID         string
 	UserID     string
+	Role       string
+	Transport  string // 创建会话的协议类型（http/sse）
+	LastTool   string // 最近调用的工具
 	CreatedAt  time.Time
 	LastAccess time.Time
 	Data       map[string]interface{}
 	ExpiresAt  time.Time
+	// TokenExpiresAt 创建会话的 mcpId 令牌的过期时间，ExpiresAt 不超过该时间；零值表示不限制（匿名会话、旧版 mcpId）
+	TokenExpiresAt time.Time
 }
 
-// sessionId -> 用户信息映射
-var sessionUserInfoMap = make(map[string]struct{ UserID, Role string })
-var sessionUserInfoMapMutex sync.RWMutex // 新增：全局读写锁
-
-// 新增：公共函数来管理 sessionUserInfoMap
-
-// addSessionUserInfo 添加或更新 session 用户信息映射
-func addSessionUserInfo(sessionID, userID, role string) {
-	sessionUserInfoMapMutex.Lock()
-	defer sessionUserInfoMapMutex.Unlock()
-	sessionUserInfoMap[sessionID] = struct{ UserID, Role string }{userID, role}
-}
-
-// removeSessionUserInfo 删除 session 用户信息映射
-func removeSessionUserInfo(sessionID string) {
-	sessionUserInfoMapMutex.Lock()
-	defer sessionUserInfoMapMutex.Unlock()
-	delete(sessionUserInfoMap, sessionID)
-}
-
-// getSessionUserInfo 获取 session 用户信息
-func getSessionUserInfo(sessionID string) (string, string) {
-	sessionUserInfoMapMutex.RLock()
-	defer sessionUserInfoMapMutex.RUnlock()
-	if info, ok := sessionUserInfoMap[sessionID]; ok {
-		return info.UserID, info.Role
-	}
-	return "", ""
+// clone 返回会话的副本，Data 为独立的 map
+func (s *HTTPSession) clone() *HTTPSession {
+	c := *s
+	c.Data = maps.Clone(s.Data)
+	return &c
 }
 
-// updateSessionRole 更新 session 的角色信息
-func updateSessionRole(sessionID, userID, role string) {
-	if role != "" {
-		addSessionUserInfo(sessionID, userID, role)
-	}
+// Identity 调用方身份
+type Identity struct {
+	UserID string
+	Role   string
 }
 
-// NewHTTPSessionManager 创建 Session 管理器，expireTime 为 session 过期时长
-func NewHTTPSessionManager(expireTime time.Duration, mcpServer *MCPServer) *HTTPSessionManager {
+// NewHTTPSessionManager 创建 Session 管理器，store 为会话存储，expireTime 为 session 过期时长，
+// mcpServer 不为空时通过该管理器识别调用方身份。默认多会话策略为 share
+func NewHTTPSessionManager(store SessionStore, expireTime time.Duration, mcpServer *MCPServer) *HTTPSessionManager {
 	sm := &HTTPSessionManager{
-		sessions:   make(map[string]*HTTPSession),
+		store:      store,
+		server:     mcpServer,
+		policy:     SessionPolicy{Mode: SessionPolicyShare},
+		identities: make(map[string]Identity),
 		cleanup:    time.NewTicker(1 * time.Minute),
 		expireTime: expireTime,
 	}
-	go sm.cleanupExpiredSessions(mcpServer)
+	if mcpServer != nil {
+		mcpServer.sessions = sm
+	}
+	go sm.cleanupExpiredSessions()
 	return sm
 }
 
-// CreateSession 创建新 session
-func (sm *HTTPSessionManager) CreateSession(userID string) *HTTPSession {
+// SetPolicy 设置同一用户的多会话策略，调用方需先校验策略
+func (sm *HTTPSessionManager) SetPolicy(policy SessionPolicy) {
 	sm.mutex.Lock()
 	defer sm.mutex.Unlock()
+	sm.policy = policy
+}
+
+// Identity 返回会话对应的调用方身份，本副本未处理过的会话从会话存储加载，会话不存在或已过期时返回 false
+func (sm *HTTPSessionManager) Identity(sessionID string) (Identity, bool) {
+	sm.mutex.Lock()
+	id, ok := sm.identities[sessionID]
+	sm.mutex.Unlock()
+	if ok {
+		return id, true
+	}
+	if sessionID == "" {
+		return Identity{}, false
+	}
+	session, err := sm.store.Get(sessionID)
+	if err != nil {
+		klog.Errorf("[SESSION] get session %s failed: %v", sessionID, err)
+		return Identity{}, false
+	}
+	if session == nil || time.Now().After(session.ExpiresAt) {
+		return Identity{}, false
+	}
+	sm.track(session)
+	return Identity{UserID: session.UserID, Role: session.Role}, true
+}
+
+// CreateSession 为用户创建新 session，按多会话策略复用、拒绝或淘汰该用户已有的 session，
+// limit 策略下会话数已达上限时返回 ErrSessionLimit。匿名 session（userID 为空）不受策略限制
+func (sm *HTTPSessionManager) CreateSession(userID, role string) (*HTTPSession, error) {
+	return sm.CreateTokenSession(userID, role, time.Time{})
+}
 
-	if !AllowMultiSession && userID != "" {
-		for _, s := range sm.sessions {
-			if s.UserID == userID {
-				return s
+// CreateTokenSession 同 CreateSession，session 的有效期不超过令牌过期时间 tokenExpiresAt（零值表示不限制），
+// share 策略下复用的 session 取已有和本次令牌中较早的非零过期时间，旧版 mcpId 加入不会解除限制
+func (sm *HTTPSessionManager) CreateTokenSession(userID, role string, tokenExpiresAt time.Time) (*HTTPSession, error) {
+	sm.mutex.Lock()
+	policy := sm.policy
+	sm.mutex.Unlock()
+
+	now := time.Now()
+	if userID != "" {
+		defer sm.userLocks.Lock(userID)()
+		sessions, err := sm.activeSessions(userID, now)
+		if err != nil {
+			return nil, err
+		}
+		switch policy.Mode {
+		case SessionPolicyShare:
+			if len(sessions) > 0 {
+				s := sessions[0]
+				tokenExpiresAt = earliestExpiry(s.TokenExpiresAt, tokenExpiresAt)
+				if (role != "" && s.Role != role) || !s.TokenExpiresAt.Equal(tokenExpiresAt) {
+					if role != "" {
+						s.Role = role
+					}
+					s.TokenExpiresAt = tokenExpiresAt
+					s.ExpiresAt = sm.expiry(s, now)
+					sm.save(s)
+				}
+				sm.track(s)
+				return s, nil
+			}
+		case SessionPolicyLimit:
+			if len(sessions) >= policy.MaxPerUser {
+				return nil, fmt.Errorf("%w: user %s already has %d sessions", ErrSessionLimit, userID, len(sessions))
+			}
+		case SessionPolicyEvict:
+			for ; len(sessions) >= policy.MaxPerUser; sessions = sessions[1:] {
+				klog.Infof("[SESSION] evict oldest session %s of user %s", sessions[0].ID, userID)
+				sm.remove(sessions[0].ID)
 			}
 		}
 	}
 
-	now := time.Now()
 	session := &HTTPSession{
-		ID:         generateSessionID(),
-		UserID:     userID,
-		CreatedAt:  now,
-		LastAccess: now,
-		Data:       make(map[string]interface{}),
-		ExpiresAt:  now.Add(sm.expireTime),
-	}
-	sm.sessions[session.ID] = session
-	// 新增：维护 sessionId -> 用户信息映射
-	role := ""
-	if v, ok := session.Data["role"]; ok {
-		if s, ok := v.(string); ok {
-			role = s
+		ID:             generateSessionID(),
+		UserID:         userID,
+		Role:           role,
+		Transport:      transport,
+		CreatedAt:      now,
+		LastAccess:     now,
+		Data:           make(map[string]interface{}),
+		TokenExpiresAt: tokenExpiresAt,
+	}
+	session.ExpiresAt = sm.expiry(session, now)
+	sm.save(session)
+	sm.track(session)
+	return session, nil
+}
+
+// ListSessions 查询未过期的 session，userID 为空时查询全部，按创建时间排序
+func (sm *HTTPSessionManager) ListSessions(userID string) ([]*HTTPSession, error) {
+	return sm.activeSessions(userID, time.Now())
+}
+
+// activeSessions 查询未过期的 session，按创建时间排序
+func (sm *HTTPSessionManager) activeSessions(userID string, now time.Time) ([]*HTTPSession, error) {
+	sessions, err := sm.store.List(userID)
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	active := sessions[:0]
+	for _, s := range sessions {
+		if !now.After(s.ExpiresAt) {
+			active = append(active, s)
 		}
 	}
-	addSessionUserInfo(session.ID, userID, role)
-	return session
+	return active, nil
 }
 
 // GetSession 获取 session 并延长有效期
-func (sm *HTTPSessionManager) GetSession(sessionID string, mcpServer *MCPServer) (*HTTPSession, bool) {
-	sm.mutex.Lock()
-	defer sm.mutex.Unlock()
+func (sm *HTTPSessionManager) GetSession(sessionID string) (*HTTPSession, bool) {
+	defer sm.sessionLocks.Lock(sessionID)()
 
-	session, exists := sm.sessions[sessionID]
-	if !exists || time.Now().After(session.ExpiresAt) {
-		if exists {
-			delete(sm.sessions, sessionID)
-			removeSessionUserInfo(sessionID) // 同步删除映射
-			// 同步调用 MCPServer 的 UnregisterSession 函数
-			if mcpServer != nil {
-				mcpServer.UnregisterSession(sessionID)
-			}
+	session, err := sm.store.Get(sessionID)
+	if err != nil {
+		klog.Errorf("[SESSION] get session %s failed: %v", sessionID, err)
+		return nil, false
+	}
+	now := time.Now()
+	if session == nil || now.After(session.ExpiresAt) {
+		if session != nil {
+			sm.remove(sessionID)
+		} else {
+			sm.release(sessionID)
 		}
 		return nil, false
 	}
 	// 更新访问时间和过期时间
-	now := time.Now()
 	session.LastAccess = now
-	session.ExpiresAt = now.Add(sm.expireTime)
+	session.ExpiresAt = sm.expiry(session, now)
+	sm.save(session)
+	// 重启前或其他副本创建的会话，同步到本副本
+	sm.track(session)
 	return session, true
 }
 
-// DeleteSession 主动删除 session
-func (sm *HTTPSessionManager) DeleteSession(sessionID string, mcpServer *MCPServer) {
-	sm.mutex.Lock()
-	defer sm.mutex.Unlock()
-	delete(sm.sessions, sessionID)
-	removeSessionUserInfo(sessionID) // 同步删除映射
+// expiry 返回 session 在 now 访问后的过期时间，不超过令牌过期时间
+func (sm *HTTPSessionManager) expiry(session *HTTPSession, now time.Time) time.Time {
+	expiresAt := now.Add(sm.expireTime)
+	if !session.TokenExpiresAt.IsZero() && session.TokenExpiresAt.Before(expiresAt) {
+		return session.TokenExpiresAt
+	}
+	return expiresAt
+}
 
-	// 同步调用 MCPServer 的 UnregisterSession 函数
-	if mcpServer != nil {
-		mcpServer.UnregisterSession(sessionID)
+// earliestExpiry 返回两个令牌过期时间中较早的一个，零值表示不限制，只有两者都为零时返回零值
+func earliestExpiry(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+	return a
+}
+
+// SaveSession 保存对 session 的修改（如 Role、Data）
+func (sm *HTTPSessionManager) SaveSession(session *HTTPSession) {
+	defer sm.sessionLocks.Lock(session.ID)()
+	sm.save(session)
+	sm.track(session)
+}
+
+// RecordToolCall 记录 session 最近调用的工具，返回 session 数据的副本，session 不存在时返回 nil
+func (sm *HTTPSessionManager) RecordToolCall(sessionID, tool string) map[string]any {
+	defer sm.sessionLocks.Lock(sessionID)()
+	session, err := sm.store.Get(sessionID)
+	if err != nil || session == nil {
+		return nil
+	}
+	session.LastTool = tool
+	sm.save(session)
+	return session.Data
+}
+
+// SetData 修改 session 数据，value 为 nil 的项被删除；session 不存在或已过期时返回 errSessionNotFound
+func (sm *HTTPSessionManager) SetData(sessionID string, values map[string]any) error {
+	defer sm.sessionLocks.Lock(sessionID)()
+	session, err := sm.store.Get(sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil || time.Now().After(session.ExpiresAt) {
+		return fmt.Errorf("%w: %s", errSessionNotFound, sessionID)
+	}
+	if session.Data == nil {
+		session.Data = make(map[string]interface{})
+	}
+	for k, v := range values {
+		if v == nil {
+			delete(session.Data, k)
+		} else {
+			session.Data[k] = v
+		}
+	}
+	return sm.store.Save(session)
+}
+
+// Data 返回 session 数据的副本，session 不存在时返回 nil
+func (sm *HTTPSessionManager) Data(sessionID string) map[string]any {
+	session, err := sm.store.Get(sessionID)
+	if err != nil || session == nil {
+		return nil
 	}
+	return maps.Clone(session.Data)
+}
+
+// DeleteSession 主动删除 session
+func (sm *HTTPSessionManager) DeleteSession(sessionID string) {
+	defer sm.sessionLocks.Lock(sessionID)()
+	sm.remove(sessionID)
 }
 
 // AddSession 允许外部以指定 ID 添加 session
 func (sm *HTTPSessionManager) AddSession(session *HTTPSession) {
+	defer sm.sessionLocks.Lock(session.ID)()
+	sm.save(session)
+	sm.track(session)
+}
+
+// save 写入会话存储，失败时仅记录日志，会话在本次请求内仍然有效
+func (sm *HTTPSessionManager) save(session *HTTPSession) {
+	if err := sm.store.Save(session); err != nil {
+		klog.Errorf("[SESSION] save session %s failed: %v", session.ID, err)
+	}
+}
+
+// track 记录本副本处理过的会话及其身份
+func (sm *HTTPSessionManager) track(session *HTTPSession) {
 	sm.mutex.Lock()
 	defer sm.mutex.Unlock()
-	sm.sessions[session.ID] = session
-	// 新增：维护 sessionId -> 用户信息映射
-	role := ""
-	if v, ok := session.Data["role"]; ok {
-		if s, ok := v.(string); ok {
-			role = s
-		}
+	sm.identities[session.ID] = Identity{UserID: session.UserID, Role: session.Role}
+}
+
+// remove 从会话存储中删除 session 并清理其在本副本的状态
+func (sm *HTTPSessionManager) remove(sessionID string) {
+	if err := sm.store.Delete(sessionID); err != nil {
+		klog.Errorf("[SESSION] delete session %s failed: %v", sessionID, err)
+	}
+	sm.release(sessionID)
+}
+
+// release 清理会话在本副本的状态：身份缓存和 MCP 服务器中的注册
+func (sm *HTTPSessionManager) release(sessionID string) {
+	sm.mutex.Lock()
+	delete(sm.identities, sessionID)
+	sm.mutex.Unlock()
+	// 同步调用 MCPServer 的 UnregisterSession 函数
+	if sm.server != nil {
+		sm.server.UnregisterSession(sessionID)
 	}
-	addSessionUserInfo(session.ID, session.UserID, role)
 }
 
-// cleanupExpiredSessions 定时清理过期 session
-func (sm *HTTPSessionManager) cleanupExpiredSessions(mcpServer *MCPServer) {
+// cleanupExpiredSessions 定时清理过期 session，并注销已被其他副本清理或删除的 session
+func (sm *HTTPSessionManager) cleanupExpiredSessions() {
 	for range sm.cleanup.C {
-		now := time.Now()
+		ids, err := sm.store.DeleteExpired(time.Now())
+		if err != nil {
+			klog.Errorf("[SESSION] delete expired sessions failed: %v", err)
+			continue
+		}
+		for _, id := range ids {
+			sm.release(id)
+		}
 		sm.mutex.Lock()
-		for id, session := range sm.sessions {
-			if now.After(session.ExpiresAt) {
-				delete(sm.sessions, id)
-				removeSessionUserInfo(id) // 同步删除映射
-				// 同步调用 MCPServer 的 UnregisterSession 函数
-				if mcpServer != nil {
-					mcpServer.UnregisterSession(id)
-				}
-			}
+		tracked := make([]string, 0, len(sm.identities))
+		for id := range sm.identities {
+			tracked = append(tracked, id)
 		}
 		sm.mutex.Unlock()
+		for _, id := range tracked {
+			if s, err := sm.store.Get(id); err == nil && s == nil {
+				sm.release(id)
+			}
+		}
 	}
 }
 
-func SessionMiddleware(sm *HTTPSessionManager, mcpServer *MCPServer, next http.Handler) http.Handler {
+func SessionMiddleware(sm *HTTPSessionManager, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		klog.Infof("[SESSION_TRACE] ===== START: RequestURI: %s =====", r.RequestURI)
 		sid := r.Header.Get(common.HeaderMcpSessionId)
@@ -199,9 +439,10 @@ func SessionMiddleware(sm *HTTPSessionManager, mcpServer *MCPServer, next http.H
 		mcpId := r.URL.Query().Get(common.McpIDParam)
 		var ses *HTTPSession
 		var userId, userRole string
+		var tokenExpiresAt time.Time
 
 		if mcpId != "" {
-			klog.Infof("[SESSION_TRACE] 2. Found mcpId in URL: '%s'", mcpId)
+			klog.Infof("[SESSION_TRACE] 2. Found mcpId in URL (%d bytes)", len(mcpId))
 			mcpId = strings.TrimSpace(mcpId)
 			var err error
 			mcpId, err = url.QueryUnescape(mcpId)
@@ -209,7 +450,7 @@ func SessionMiddleware(sm *HTTPSessionManager, mcpServer *MCPServer, next http.H
 				klog.Infof("[SESSION_TRACE] 2a. ERROR unescaping mcpId: %v", err)
 			}
 			mcpId = strings.ReplaceAll(mcpId, " ", "+")
-			userId, userRole = ParseUserIDAndRoleFromSID(mcpId)
+			userId, userRole, tokenExpiresAt = ParseUserIDAndRoleFromSID(mcpId)
 			klog.Infof("[SESSION_TRACE] 2b. Parsed mcpId: userId=%s, userRole=%s", userId, userRole)
 		} else {
 			klog.Infof("[SESSION_TRACE] 2. mcpId not found in URL.")
@@ -217,15 +458,13 @@ func SessionMiddleware(sm *HTTPSessionManager, mcpServer *MCPServer, next http.H
 
 		if sid != "" {
 			klog.Infof("[SESSION_TRACE] 3. Attempting to get session with sid: '%s'", sid)
-			if s, ok := sm.GetSession(sid, mcpServer); ok {
+			if s, ok := sm.GetSession(sid); ok {
 				ses = s
 				klog.Infof("[SESSION_TRACE] 3a. SUCCESS: Found active session: ID=%s, UserID=%s, ExpiresAt=%v", ses.ID, ses.UserID, ses.ExpiresAt)
-				if userRole != "" {
-					if v, ok := ses.Data["role"]; !ok || v == "" {
-						ses.Data["role"] = userRole
-						updateSessionRole(ses.ID, ses.UserID, userRole)
-						klog.Infof("[SESSION_TRACE] 3b. UPDATED session role from mcpId: role=%s", userRole)
-					}
+				if userRole != "" && ses.Role == "" {
+					ses.Role = userRole
+					sm.SaveSession(ses)
+					klog.Infof("[SESSION_TRACE] 3b. UPDATED session role from mcpId: role=%s", userRole)
 				}
 			} else {
 				klog.Infof("[SESSION_TRACE] 3a. FAILED: No active session found for sid: '%s'", sid)
@@ -236,16 +475,27 @@ func SessionMiddleware(sm *HTTPSessionManager, mcpServer *MCPServer, next http.H
 
 		if ses == nil && userId != "" {
 			klog.Infof("[SESSION_TRACE] 4. Creating new session from mcpId: userId=%s, userRole=%s", userId, userRole)
-			ses = sm.CreateSession(userId)
-			ses.Data["role"] = userRole
+			var err error
+			if ses, err = sm.CreateTokenSession(userId, userRole, tokenExpiresAt); err != nil {
+				klog.Warningf("[SESSION_TRACE] 4a. FAILED: create session for user %s: %v", userId, err)
+				status := http.StatusServiceUnavailable
+				if errors.Is(err, ErrSessionLimit) {
+					status = http.StatusTooManyRequests
+				}
+				http.Error(w, err.Error(), status)
+				return
+			}
 			w.Header().Set(common.HeaderMcpSessionId, ses.ID)
-			updateSessionRole(ses.ID, ses.UserID, userRole)
 			klog.Infof("[SESSION_TRACE] 4a. SUCCESS: Created new session: ID=%s", ses.ID)
 		}
 
 		if ses == nil {
 			klog.Infof("[SESSION_TRACE] 5. Creating new EMPTY session.")
-			ses = sm.CreateSession("")
+			var err error
+			if ses, err = sm.CreateSession("", ""); err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
 			w.Header().Set(common.HeaderMcpSessionId, ses.ID)
 			klog.Infof("[SESSION_TRACE] 5a. SUCCESS: Created new empty session: ID=%s", ses.ID)
 		}
@@ -264,42 +514,42 @@ func SessionMiddleware(sm *HTTPSessionManager, mcpServer *MCPServer, next http.H
 		ctx := context.WithValue(r.Context(), common.ContextKeyMcpSession, ses.ID)
 		klog.Infof("[SESSION_TRACE] 7. Injecting sid '%s' into request context.", ses.ID)
 
-		// 只在这里统一调用一次 RegisterSession
-		if mcpServer != nil {
-			mcpServer.RegisterSession(ses.ID)
-		}
-
 		klog.Infof("[SESSION_TRACE] ===== END: Passing request to next handler =====")
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-// 通过 sid 解密出用户信息（如 userID 和 role）
-func ParseUserIDAndRoleFromSID(sid string) (string, string) {
+// ParseUserIDAndRoleFromSID 校验 mcpId 令牌并解析出用户 ID、角色和令牌过期时间，校验失败时返回空。
+// 旧版 AES-CBC 格式仅在 AllowLegacyMcpID 时接受，没有过期时间
+func ParseUserIDAndRoleFromSID(sid string) (string, string, time.Time) {
+	if strings.HasPrefix(sid, crypto.TokenPrefix) {
+		if TokenKeys == nil {
+			klog.Warning("[SESSION] mcpId token rejected: no token key configured")
+			return "", "", time.Time{}
+		}
+		claims, err := TokenKeys.ParseToken(sid, time.Now())
+		if err != nil {
+			klog.Warningf("[SESSION] mcpId token rejected: %v", err)
+			return "", "", time.Time{}
+		}
+		klog.Infof("[SESSION] mcpId token accepted: user=%s, role=%s, jti=%s", claims.Name, claims.Role, claims.ID)
+		return claims.Name, claims.Role, time.Unix(claims.ExpiresAt, 0)
+	}
+	if !AllowLegacyMcpID {
+		klog.Warning("[SESSION] legacy mcpId rejected, enable -allow-legacy-mcpid or issue a new token")
+		return "", "", time.Time{}
+	}
 	plain, err := crypto.AESDecryptBase64(sid, AESKey)
 	if err != nil {
-		return "", ""
+		return "", "", time.Time{}
 	}
 	var obj struct {
 		Name string `json:"name"`
 		Role string `json:"role"`
 	}
 	if err := json.Unmarshal([]byte(plain), &obj); err != nil {
-		return "", ""
+		return "", "", time.Time{}
 	}
-	return obj.Name, obj.Role
-}
-
-// 线程安全获取 sessionId 对应的用户角色
-func GetUserRoleBySessionID(sessionID string) string {
-	_, role := getSessionUserInfo(sessionID)
-	klog.Infof("[SESSION] GetUserRoleBySessionID: sessionID=%s, role=%s", sessionID, role)
-	return role
-}
-
-// 线程安全获取 sessionId 对应的用户ID
-func GetUserIDBySessionID(sessionID string) string {
-	userID, _ := getSessionUserInfo(sessionID)
-	klog.Infof("[SESSION] GetUserIDBySessionID: sessionID=%s, userID=%s", sessionID, userID)
-	return userID
+	klog.Warningf("[SESSION] deprecated legacy mcpId accepted: user=%s, role=%s", obj.Name, obj.Role)
+	return obj.Name, obj.Role, time.Time{}
 }
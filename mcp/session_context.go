@@ -0,0 +1,169 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"github.com/relaxyabc/k8s-helper/dao"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// 会话上下文在 HTTPSession.Data 中的 key
+const (
+	sessionDataCluster   = "current_cluster"   // use_cluster 选择的当前集群
+	sessionDataNamespace = "current_namespace" // use_namespace 选择的当前命名空间
+)
+
+// callContextKey 工具调用 ctx 中会话当前上下文的 key
+type callContextKey struct{}
+
+// callContext 本次工具调用读取到的会话当前上下文，use_cluster、use_namespace 修改后同步更新
+type callContext struct {
+	current map[string]string
+}
+
+// loadSession 中间件，每次工具调用只读取一次会话：记录最近调用的工具，并将当前上下文放入 ctx，stdio 模式不处理
+func (s *MCPServer) loadSession(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if s.sessions != nil {
+			data := s.sessions.RecordToolCall(sessionIDFromContext(ctx), request.Params.Name)
+			current := map[string]string{}
+			for _, key := range []string{sessionDataCluster, sessionDataNamespace} {
+				if v, _ := data[key].(string); v != "" {
+					current[key] = v
+				}
+			}
+			ctx = context.WithValue(ctx, callContextKey{}, &callContext{current: current})
+		}
+		return next(ctx, request)
+	}
+}
+
+// currentContext 返回会话当前的集群和命名空间，未设置的项不在结果中，stdio 模式返回 nil
+func currentContext(ctx context.Context) map[string]string {
+	if c, ok := ctx.Value(callContextKey{}).(*callContext); ok {
+		return c.current
+	}
+	return nil
+}
+
+// setContext 修改会话当前的集群和命名空间，值为空串时清除该项，返回修改后的当前上下文
+func (s *MCPServer) setContext(ctx context.Context, values map[string]string) (map[string]string, error) {
+	if s.sessions == nil {
+		return nil, errNoSessionManager
+	}
+	data := make(map[string]any, len(values))
+	for k, v := range values {
+		if v == "" {
+			data[k] = nil
+		} else {
+			data[k] = v
+		}
+	}
+	if err := s.sessions.SetData(sessionIDFromContext(ctx), data); err != nil {
+		return nil, err
+	}
+	current := maps.Clone(currentContext(ctx))
+	if current == nil {
+		current = map[string]string{}
+	}
+	for k, v := range values {
+		if v == "" {
+			delete(current, k)
+		} else {
+			current[k] = v
+		}
+	}
+	if c, ok := ctx.Value(callContextKey{}).(*callContext); ok {
+		c.current = current
+	}
+	return current, nil
+}
+
+// contextText 返回附加在工具结果后的当前上下文说明
+func contextText(current map[string]string) string {
+	cluster, namespace := current[sessionDataCluster], current[sessionDataNamespace]
+	if cluster == "" && namespace == "" {
+		return "当前上下文: 未选择集群和命名空间，可通过 use_cluster、use_namespace 选择"
+	}
+	if cluster == "" {
+		cluster = "未设置"
+	}
+	if namespace == "" {
+		namespace = "未设置"
+	}
+	return fmt.Sprintf("当前上下文: cluster=%s, namespace=%s", cluster, namespace)
+}
+
+// appendContext 中间件，在每个工具结果末尾附加当前上下文，未选择时也明确说明，stdio 模式不附加
+func (s *MCPServer) appendContext(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := next(ctx, request)
+		if err != nil || result == nil {
+			return result, err
+		}
+		if current := currentContext(ctx); current != nil {
+			result.Content = append(result.Content, mcp.NewTextContent(contextText(current)))
+		}
+		return result, nil
+	}
+}
+
+// registerContextTools 注册会话上下文工具，选择后其他工具可省略 cluster_name 和 namespace
+func (s *MCPServer) registerContextTools() {
+	// use_cluster
+	s.registerTool(toolSpec{
+		Name:        "use_cluster",
+		Description: "Select the current cluster of this session, later tools may omit cluster_name; the current namespace is cleared unless namespace is given",
+		Method:      "POST",
+		Path:        "/use_cluster",
+		Params: []toolParam{
+			paramManagedCluster,
+			{Name: "namespace", Type: paramString, Description: "同时选择的当前命名空间，为空时清除当前命名空间"},
+		},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			clusterName := args.String("cluster_name")
+			if _, err := dao.GetCluster(clusterName); err != nil {
+				return mcp.NewToolResultError("查询集群失败: " + err.Error()), nil
+			}
+			current, err := s.setContext(ctx, map[string]string{
+				sessionDataCluster:   clusterName,
+				sessionDataNamespace: args.String("namespace"),
+			})
+			if err != nil {
+				return mcp.NewToolResultError("设置当前集群失败: " + err.Error()), nil
+			}
+			return jsonResult(current)
+		},
+	})
+	// use_namespace
+	s.registerTool(toolSpec{
+		Name:        "use_namespace",
+		Description: "Select the current namespace of this session within the current cluster, later tools may omit namespace; an empty namespace clears it",
+		Method:      "POST",
+		Path:        "/use_namespace",
+		Params: []toolParam{
+			{Name: "namespace", Type: paramString, Description: "命名空间，为空时清除当前命名空间"},
+		},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			namespace := args.String("namespace")
+			if namespace != "" {
+				clusterName := currentContext(ctx)[sessionDataCluster]
+				if clusterName == "" {
+					return mcp.NewToolResultError("请先通过 use_cluster 选择当前集群"), nil
+				}
+				if err := s.authorizeScope(ctx, clusterName, namespace); err != nil {
+					return mcp.NewToolResultError(err.Error()), nil
+				}
+			}
+			current, err := s.setContext(ctx, map[string]string{sessionDataNamespace: namespace})
+			if err != nil {
+				return mcp.NewToolResultError("设置当前命名空间失败: " + err.Error()), nil
+			}
+			return jsonResult(current)
+		},
+	})
+}
@@ -0,0 +1,189 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/relaxyabc/k8s-helper/dao"
+)
+
+// 会话存储类型
+const (
+	SessionStoreMemory   = "memory"   // 内存存储，重启后会话丢失
+	SessionStorePostgres = "postgres" // Postgres 存储，重启和多副本间共享会话
+)
+
+// SessionStore 会话存储，HTTPSessionManager 通过它读写会话
+type SessionStore interface {
+	// Get 按 ID 查询会话（含已过期的），不存在时返回 nil
+	Get(id string) (*HTTPSession, error)
+	// Save 新增或覆盖会话
+	Save(session *HTTPSession) error
+	// Delete 删除会话
+	Delete(id string) error
+	// List 查询会话，userID 为空时查询全部，按创建时间排序
+	List(userID string) ([]*HTTPSession, error)
+	// DeleteExpired 删除 now 之前过期的会话，返回被删除的会话 ID
+	DeleteExpired(now time.Time) ([]string, error)
+}
+
+// NewSessionStore 按类型创建会话存储
+func NewSessionStore(kind string) (SessionStore, error) {
+	switch kind {
+	case "", SessionStoreMemory:
+		return NewMemorySessionStore(), nil
+	case SessionStorePostgres:
+		return NewPostgresSessionStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown session store %q", kind)
+	}
+}
+
+// memorySessionStore 内存会话存储
+type memorySessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*HTTPSession
+}
+
+// NewMemorySessionStore 创建内存会话存储
+func NewMemorySessionStore() SessionStore {
+	return &memorySessionStore{sessions: make(map[string]*HTTPSession)}
+}
+
+// Get 返回会话副本，调用方修改后需 Save 才生效
+func (m *memorySessionStore) Get(id string) (*HTTPSession, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if s, ok := m.sessions[id]; ok {
+		return s.clone(), nil
+	}
+	return nil, nil
+}
+
+// Save 保存会话副本，之后调用方对 session 的修改不影响存储
+func (m *memorySessionStore) Save(session *HTTPSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[session.ID] = session.clone()
+	return nil
+}
+
+func (m *memorySessionStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *memorySessionStore) List(userID string) ([]*HTTPSession, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var sessions []*HTTPSession
+	for _, s := range m.sessions {
+		if userID == "" || s.UserID == userID {
+			sessions = append(sessions, s.clone())
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.Before(sessions[j].CreatedAt) })
+	return sessions, nil
+}
+
+func (m *memorySessionStore) DeleteExpired(now time.Time) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []string
+	for id, s := range m.sessions {
+		if now.After(s.ExpiresAt) {
+			delete(m.sessions, id)
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// postgresSessionStore 基于 dao 的 Postgres 会话存储，Data 以 JSON 保存
+type postgresSessionStore struct{}
+
+// NewPostgresSessionStore 创建 Postgres 会话存储，需先初始化数据库连接
+func NewPostgresSessionStore() SessionStore {
+	return postgresSessionStore{}
+}
+
+func (postgresSessionStore) Get(id string) (*HTTPSession, error) {
+	row, err := dao.GetSession(id)
+	if err != nil || row == nil {
+		return nil, err
+	}
+	return sessionFromRow(row)
+}
+
+func (postgresSessionStore) Save(session *HTTPSession) error {
+	data, err := json.Marshal(session.Data)
+	if err != nil {
+		return fmt.Errorf("marshal session data: %w", err)
+	}
+	return dao.SaveSession(&dao.Session{
+		ID:             session.ID,
+		UserID:         session.UserID,
+		Role:           session.Role,
+		Transport:      session.Transport,
+		LastTool:       session.LastTool,
+		Data:           string(data),
+		CreatedAt:      session.CreatedAt,
+		LastAccess:     session.LastAccess,
+		ExpiresAt:      session.ExpiresAt,
+		TokenExpiresAt: session.TokenExpiresAt,
+	})
+}
+
+func (postgresSessionStore) Delete(id string) error {
+	return dao.DeleteSession(id)
+}
+
+func (postgresSessionStore) List(userID string) ([]*HTTPSession, error) {
+	rows, err := dao.ListSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]*HTTPSession, 0, len(rows))
+	for i := range rows {
+		s, err := sessionFromRow(&rows[i])
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, nil
+}
+
+func (postgresSessionStore) DeleteExpired(now time.Time) ([]string, error) {
+	return dao.DeleteExpiredSessions(now)
+}
+
+// sessionFromRow 将数据库记录转换为会话
+func sessionFromRow(row *dao.Session) (*HTTPSession, error) {
+	s := &HTTPSession{
+		ID:             row.ID,
+		UserID:         row.UserID,
+		Role:           row.Role,
+		Transport:      row.Transport,
+		LastTool:       row.LastTool,
+		CreatedAt:      row.CreatedAt,
+		LastAccess:     row.LastAccess,
+		ExpiresAt:      row.ExpiresAt,
+		Data:           make(map[string]interface{}),
+		TokenExpiresAt: row.TokenExpiresAt,
+	}
+	if row.Data != "" {
+		if err := json.Unmarshal([]byte(row.Data), &s.Data); err != nil {
+			return nil, fmt.Errorf("unmarshal data of session %s: %w", row.ID, err)
+		}
+		if s.Data == nil {
+			s.Data = make(map[string]interface{})
+		}
+	}
+	return s, nil
+}
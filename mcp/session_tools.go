@@ -0,0 +1,158 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"k8s.io/klog/v2"
+)
+
+// sessionInfo 会话管理工具返回的会话信息，不含会话数据
+type sessionInfo struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	Role       string    `json:"role"`
+	Transport  string    `json:"transport"`
+	LastTool   string    `json:"last_tool,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastAccess time.Time `json:"last_access"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// newSessionInfo 转换为会话信息
+func newSessionInfo(s *HTTPSession) sessionInfo {
+	return sessionInfo{
+		ID:         s.ID,
+		UserID:     s.UserID,
+		Role:       s.Role,
+		Transport:  s.Transport,
+		LastTool:   s.LastTool,
+		CreatedAt:  s.CreatedAt,
+		LastAccess: s.LastAccess,
+		ExpiresAt:  s.ExpiresAt,
+	}
+}
+
+// errNoSessionManager stdio 模式没有会话管理器
+var errNoSessionManager = errors.New("stdio 模式没有会话管理")
+
+// errSessionNotFound 会话不存在、已过期或不属于指定用户
+var errSessionNotFound = errors.New("会话不存在")
+
+// listSessions 查询未过期的会话，userID 为空时查询全部
+func (s *MCPServer) listSessions(userID string) ([]sessionInfo, error) {
+	if s.sessions == nil {
+		return nil, errNoSessionManager
+	}
+	sessions, err := s.sessions.ListSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]sessionInfo, 0, len(sessions))
+	for _, ses := range sessions {
+		infos = append(infos, newSessionInfo(ses))
+	}
+	return infos, nil
+}
+
+// terminateSessions 强制结束会话并从 MCP 服务器注销：sessionID 不为空时只结束该会话（userID 不为空时需属于该用户），
+// 否则结束 userID 的全部会话，返回被结束的会话 ID
+func (s *MCPServer) terminateSessions(userID, sessionID string) ([]string, error) {
+	if s.sessions == nil {
+		return nil, errNoSessionManager
+	}
+	if userID == "" && sessionID == "" {
+		return nil, errors.New("user_id 和 session_id 至少指定一个")
+	}
+	sessions, err := s.sessions.ListSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+	terminated := []string{}
+	for _, ses := range sessions {
+		if sessionID != "" && ses.ID != sessionID {
+			continue
+		}
+		s.sessions.DeleteSession(ses.ID)
+		terminated = append(terminated, ses.ID)
+	}
+	if sessionID != "" && len(terminated) == 0 {
+		return nil, fmt.Errorf("%w: %s", errSessionNotFound, sessionID)
+	}
+	klog.Infof("[SESSION] terminated sessions of user %s: %v", userID, terminated)
+	return terminated, nil
+}
+
+// registerSessionTools 注册会话管理工具，内置策略仅 admin 可用
+func (s *MCPServer) registerSessionTools() {
+	// list_sessions
+	s.registerTool(toolSpec{
+		Name:        "list_sessions",
+		Description: "List active sessions with user, role, transport, created time, last access, expiry and last tool called",
+		Method:      "GET",
+		Path:        "/sessions",
+		Params: []toolParam{
+			{Name: "user_id", Type: paramString, Description: "用户 ID，为空时查询全部会话"},
+		},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			infos, err := s.listSessions(args.String("user_id"))
+			if err != nil {
+				return mcp.NewToolResultError("查询会话失败: " + err.Error()), nil
+			}
+			return jsonResult(infos)
+		},
+	})
+	// terminate_sessions
+	s.registerTool(toolSpec{
+		Name:        "terminate_sessions",
+		Description: "Force-terminate (revoke) all sessions of a user, or only the given session",
+		Method:      "POST",
+		Path:        "/terminate_sessions",
+		Params: []toolParam{
+			{Name: "user_id", Type: paramString, Description: "用户 ID，与 session_id 至少指定一个"},
+			{Name: "session_id", Type: paramString, Description: "只结束该会话，为空时结束用户的全部会话"},
+		},
+		Handler: func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error) {
+			terminated, err := s.terminateSessions(args.String("user_id"), args.String("session_id"))
+			if err != nil {
+				return mcp.NewToolResultError("结束会话失败: " + err.Error()), nil
+			}
+			return jsonResult(map[string]any{"terminated": terminated})
+		},
+	})
+}
+
+// RegisterSessionHandlers 注册会话管理 HTTP 接口，调用方角色需有对应工具的权限：
+// GET /admin/sessions?user_id=xxx 查询会话（list_sessions），user_id 为空时查询全部
+// DELETE /admin/sessions/{id} 撤销会话并从 MCP 服务器注销（terminate_sessions）
+func (s *MCPServer) RegisterSessionHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("GET /admin/sessions", func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := s.authorizeHTTP(w, r, "list_sessions"); !ok {
+			return
+		}
+		infos, err := s.listSessions(r.URL.Query().Get("user_id"))
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, infos)
+	})
+	mux.HandleFunc("DELETE /admin/sessions/{id}", s.auditHTTP("terminate_sessions", func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := s.authorizeHTTP(w, r, "terminate_sessions"); !ok {
+			return
+		}
+		_, err := s.terminateSessions("", r.PathValue("id"))
+		switch {
+		case errors.Is(err, errSessionNotFound):
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+}
@@ -0,0 +1,300 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"k8s.io/klog/v2"
+)
+
+// HTTPStyleTools 是否以旧版 HTTP 风格（method/url/body）暴露工具，兼容存量客户端，默认 false
+var HTTPStyleTools = false
+
+// 工具参数类型
+const (
+	paramString = "string"
+	paramNumber = "number"
+	paramBool   = "boolean"
+)
+
+// toolParam 工具参数定义
+type toolParam struct {
+	Name        string
+	Type        string
+	Description string
+	Required    bool
+	Enum        []string
+	Context     string // 省略时取会话当前上下文中该项的值，如 sessionDataCluster
+}
+
+// toolSpec 工具定义，同一份定义可生成带类型的参数 schema 或 HTTP 风格 schema
+type toolSpec struct {
+	Name           string
+	Description    string
+	Method         string // HTTP 风格下要求的 method
+	Path           string // HTTP 风格下要求的 url 路径
+	Mutating       bool   // 是否修改集群资源或集群配置，非 admin 角色或受保护集群的调用需要审批
+	Params         []toolParam
+	Validate       func(args toolArgs) error               // 可选的参数校验，在创建审批和调用 handler 之前执行
+	ApprovalReason func(role string, args toolArgs) string // 可选的审批判断，返回需要审批的原因，未定义时按 approvalReason 判断
+	Handler        func(ctx context.Context, args toolArgs) (*mcp.CallToolResult, error)
+}
+
+// approvalReason 返回变更类工具调用需要审批的原因，无需审批时返回空串
+func (t toolSpec) approvalReason(role string, args toolArgs) string {
+	if t.ApprovalReason != nil {
+		return t.ApprovalReason(role, args)
+	}
+	return approvalReason(role, args.String("cluster_name"))
+}
+
+// validate 执行工具自定义的参数校验，未定义时直接通过
+func (t toolSpec) validate(args toolArgs) error {
+	if t.Validate == nil {
+		return nil
+	}
+	return t.Validate(args)
+}
+
+// toolArgs 工具调用参数，值可能来自 JSON arguments 或 HTTP 风格 url 的 query string
+type toolArgs map[string]any
+
+// String 获取字符串参数，不存在时返回空串
+func (a toolArgs) String(key string) string {
+	switch v := a[key].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Int 获取整数参数，不存在或非法时返回 def
+func (a toolArgs) Int(key string, def int) int {
+	switch v := a[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case string:
+		if i, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			return i
+		}
+	}
+	return def
+}
+
+// Int32 获取 int32 范围内的整数参数，不存在、不是整数或超出范围时返回 false
+func (a toolArgs) Int32(key string) (int32, bool) {
+	var f float64
+	switch v := a[key].(type) {
+	case float64:
+		f = v
+	case int:
+		f = float64(v)
+	case string:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, false
+		}
+		f = parsed
+	default:
+		return 0, false
+	}
+	if f != math.Trunc(f) || f < math.MinInt32 || f > math.MaxInt32 {
+		return 0, false
+	}
+	return int32(f), true
+}
+
+// Bool 获取布尔参数，不存在或非法时返回 def
+func (a toolArgs) Bool(key string, def bool) bool {
+	switch v := a[key].(type) {
+	case bool:
+		return v
+	case string:
+		if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+			return b
+		}
+	}
+	return def
+}
+
+// usage 返回 HTTP 风格下的调用示例，如 GET /pods?cluster_name=xxx&namespace=xxx
+func (t toolSpec) usage() string {
+	var kvs []string
+	for _, p := range t.Params {
+		kvs = append(kvs, p.Name+"=xxx")
+	}
+	if len(kvs) == 0 {
+		return t.Method + " " + t.Path
+	}
+	return t.Method + " " + t.Path + "?" + strings.Join(kvs, "&")
+}
+
+// buildTool 根据工具定义生成 MCP 工具 schema
+func (t toolSpec) buildTool() mcp.Tool {
+	if HTTPStyleTools {
+		return mcp.NewTool(t.Name,
+			mcp.WithDescription(t.Description+"（HTTP tool 风格: "+t.usage()+"）"),
+			mcp.WithString("method", mcp.Required(), mcp.Description("HTTP method: GET/POST/PUT/DELETE"), mcp.Enum("GET", "POST", "PUT", "DELETE")),
+			mcp.WithString("url", mcp.Required(), mcp.Description("API 路径，如 /clusters /namespaces?cluster_name=xxx 等")),
+			mcp.WithString("body", mcp.Description("请求体（POST/PUT 时可选)")),
+		)
+	}
+	opts := []mcp.ToolOption{mcp.WithDescription(t.Description)}
+	for _, p := range t.Params {
+		desc := p.Description
+		if p.Context != "" {
+			desc += "，省略时使用 use_cluster/use_namespace 选择的当前值"
+		}
+		propOpts := []mcp.PropertyOption{mcp.Description(desc)}
+		// 可取自会话上下文的参数不在 schema 中标记必填，补全后再校验
+		if p.Required && p.Context == "" {
+			propOpts = append(propOpts, mcp.Required())
+		}
+		if len(p.Enum) > 0 {
+			propOpts = append(propOpts, mcp.Enum(p.Enum...))
+		}
+		switch p.Type {
+		case paramNumber:
+			opts = append(opts, mcp.WithNumber(p.Name, propOpts...))
+		case paramBool:
+			opts = append(opts, mcp.WithBoolean(p.Name, propOpts...))
+		default:
+			opts = append(opts, mcp.WithString(p.Name, propOpts...))
+		}
+	}
+	return mcp.NewTool(t.Name, opts...)
+}
+
+// parseArgs 解析并校验工具调用参数，省略的上下文参数用 current 补全
+func (t toolSpec) parseArgs(request mcp.CallToolRequest, current map[string]string) (toolArgs, error) {
+	raw := request.GetArguments()
+	args := toolArgs{}
+	if HTTPStyleTools {
+		method, _ := raw["method"].(string)
+		rawURL, _ := raw["url"].(string)
+		u, err := url.Parse(rawURL)
+		if err != nil || method != t.Method || u.Path != t.Path {
+			return nil, fmt.Errorf("仅支持 %s", t.usage())
+		}
+		for k, v := range u.Query() {
+			args[k] = v[0]
+		}
+	} else {
+		for k, v := range raw {
+			args[k] = v
+		}
+	}
+	t.applyContext(args, current)
+	var missing []string
+	for _, p := range t.Params {
+		if p.Required && args.String(p.Name) == "" {
+			missing = append(missing, p.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("参数 %s 必填", strings.Join(missing, "、"))
+	}
+	return args, nil
+}
+
+// applyContext 用会话当前上下文补全省略的参数，显式传入的值（包括空串）保持不变
+func (t toolSpec) applyContext(args toolArgs, current map[string]string) {
+	for _, p := range t.Params {
+		if p.Context == "" {
+			continue
+		}
+		if _, ok := args[p.Name]; ok {
+			continue
+		}
+		if v := current[p.Context]; v != "" {
+			args[p.Name] = v
+		}
+	}
+}
+
+// usesContext 判断工具是否有可取自会话上下文的参数
+func (t toolSpec) usesContext() bool {
+	for _, p := range t.Params {
+		if p.Context != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasParam 判断工具是否定义了指定参数
+func (t toolSpec) hasParam(name string) bool {
+	for _, p := range t.Params {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// registerTool 注册工具：解析参数、校验集群/命名空间范围、按需转为审批后调用 handler
+func (s *MCPServer) registerTool(spec toolSpec) {
+	if spec.Mutating {
+		s.mutatingTools[spec.Name] = spec
+	}
+	if spec.usesContext() {
+		s.contextTools[spec.Name] = spec
+	}
+	s.server.AddTool(spec.buildTool(), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		sid := sessionIDFromContext(ctx)
+		paramsJson, _ := json.Marshal(RedactParams(request.GetArguments()))
+		klog.Infof("[%s][%s][sessionid:%s]-%s-%s", time.Now().Format("2006-01-02 15:04:05"), transport, sid, spec.Name, string(paramsJson))
+		args, err := spec.parseArgs(request, currentContext(ctx))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if spec.hasParam("cluster_name") {
+			if err := s.authorizeScope(ctx, args.String("cluster_name"), args.String("namespace")); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+		}
+		if err := spec.validate(args); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		// dry_run 不修改资源，无需审批
+		if spec.Mutating && !args.Bool("dry_run", false) {
+			if reason := spec.approvalReason(s.identity(sid).Role, args); reason != "" {
+				return s.requestApproval(ctx, spec, args, reason)
+			}
+		}
+		return spec.Handler(ctx, args)
+	})
+}
+
+// jsonResult 将结果序列化为 JSON 文本返回
+func jsonResult(v any) (*mcp.CallToolResult, error) {
+	jsonStr, err := json.Marshal(v)
+	if err != nil {
+		return mcp.NewToolResultError("序列化失败: " + err.Error()), nil
+	}
+	return mcp.NewToolResultText(string(jsonStr)), nil
+}
+
+// 常用参数定义
+var (
+	paramClusterName = toolParam{Name: "cluster_name", Type: paramString, Required: true, Context: sessionDataCluster, Description: "集群名称，取自 get_clusters 返回的 cluster_name"}
+	paramNamespace   = toolParam{Name: "namespace", Type: paramString, Required: true, Context: sessionDataNamespace, Description: "命名空间"}
+	paramDryRun      = toolParam{Name: "dry_run", Type: paramBool, Description: "仅做服务端 dry-run 不实际修改，返回变更前后对象的 unified diff"}
+)
+
+// paramName 返回资源名称参数定义
+func paramName(kind string) toolParam {
+	return toolParam{Name: "name", Type: paramString, Required: true, Description: kind + " 名称"}
+}
@@ -0,0 +1,233 @@
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relaxyabc/k8s-helper/common"
+	"github.com/relaxyabc/k8s-helper/dao"
+	"github.com/relaxyabc/k8s-helper/mcp"
+)
+
+func TestApprovalHandlers(t *testing.T) {
+	openTestDB(t)
+	useRBACPolicy(t, `
+roles:
+  admin: {tools: ["*"], clusters: ["*"], namespaces: ["*"]}
+  oncall:
+    tools: ["rollout_restart_*", scale_workload, list_approvals, approve_request, reject_request]
+    clusters: ["*"]
+    namespaces: ["*"]
+  prod-approver:
+    tools: [approve_request, reject_request]
+    clusters: ["prod-*"]
+    namespaces: ["*"]
+`)
+	if err := dao.CreateCluster(&dao.Cluster{ClusterName: "test-bj", KubeConfig: testKubeConfig}); err != nil {
+		t.Fatalf("create cluster: %v", err)
+	}
+	s := mcp.NewMCPServer()
+	sm := mcp.NewHTTPSessionManager(mcp.NewMemorySessionStore(), time.Minute, s)
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", s.ServeHTTP())
+	s.RegisterApprovalHandlers(mux)
+	srv := httptest.NewServer(mcp.SessionMiddleware(sm, mux))
+	defer srv.Close()
+	alice, _ := sm.CreateSession("alice", "oncall")
+	bob, _ := sm.CreateSession("bob", "oncall")
+	carol, _ := sm.CreateSession("carol", "prod-approver")
+	root, _ := sm.CreateSession("root", "admin")
+
+	do := func(method, path, sid string) (int, string) {
+		req, _ := http.NewRequest(method, srv.URL+path, nil)
+		req.Header.Set(common.HeaderMcpSessionId, sid)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		t.Logf("%s %s -> %d %s", method, path, resp.StatusCode, body)
+		return resp.StatusCode, string(body)
+	}
+	restart := func() uint {
+		texts, isError := callTool(t, srv.URL, alice.ID, "rollout_restart_deployment", map[string]any{
+			"cluster_name": "test-bj", "namespace": "default", "name": "web",
+		})
+		var pending struct {
+			ApprovalID uint   `json:"approval_id"`
+			Status     string `json:"status"`
+		}
+		if err := json.Unmarshal([]byte(texts[0]), &pending); isError || err != nil || pending.Status != dao.ApprovalPending {
+			t.Fatalf("non-admin mutation should create a pending approval, got %q", texts)
+		}
+		return pending.ApprovalID
+	}
+
+	// 非 admin 的变更操作创建待审批记录，不直接执行
+	id := restart()
+	a, err := dao.GetApproval(id)
+	if err != nil || a.Status != dao.ApprovalPending || a.RequestedBy != "alice" || a.ClusterName != "test-bj" {
+		t.Fatalf("unexpected approval: %+v, %v", a, err)
+	}
+	if code, body := do(http.MethodGet, "/admin/approvals?status=pending", bob.ID); code != http.StatusOK || !strings.Contains(body, `"requested_by":"alice"`) {
+		t.Errorf("list pending approvals: got %d %s", code, body)
+	}
+
+	// 不能审批自己的请求，审批人需有权访问该集群
+	approvePath := fmt.Sprintf("/admin/approvals/%d/approve", id)
+	if code, body := do(http.MethodPost, approvePath, alice.ID); code != http.StatusConflict || !strings.Contains(body, "不能审批自己") {
+		t.Errorf("self approval: got %d %s", code, body)
+	}
+	if code, _ := do(http.MethodPost, approvePath, carol.ID); code != http.StatusForbidden {
+		t.Errorf("approver out of scope: got %d, want 403", code)
+	}
+	if a, _ := dao.GetApproval(id); a.Status != dao.ApprovalPending {
+		t.Errorf("rejected decisions should leave the approval pending, got %s", a.Status)
+	}
+
+	// 同时批准两次，只有一次能领取审批
+	codes := make(chan int, 2)
+	for _, sid := range []string{bob.ID, root.ID} {
+		go func() {
+			code, _ := do(http.MethodPost, approvePath, sid)
+			codes <- code
+		}()
+	}
+	got := map[int]int{}
+	for range 2 {
+		got[<-codes]++
+	}
+	if got[http.StatusOK] != 1 || got[http.StatusConflict] != 1 {
+		t.Errorf("double approve: got status counts %v, want one 200 and one 409", got)
+	}
+	if a, _ := dao.GetApproval(id); a.Status == dao.ApprovalPending || a.Approver == "" {
+		t.Errorf("approved request should be decided once: %+v", a)
+	}
+
+	// 拒绝后不能再批准
+	id = restart()
+	if code, _ := do(http.MethodPost, fmt.Sprintf("/admin/approvals/%d/reject", id), bob.ID); code != http.StatusOK {
+		t.Errorf("reject: got %d", code)
+	}
+	if code, _ := do(http.MethodPost, fmt.Sprintf("/admin/approvals/%d/approve", id), root.ID); code != http.StatusConflict {
+		t.Errorf("approve after reject: got %d, want 409", code)
+	}
+	if a, _ := dao.GetApproval(id); a.Status != dao.ApprovalRejected || a.Approver != "bob" {
+		t.Errorf("rejected approval: %+v", a)
+	}
+
+	// 参数不合法的调用直接返回错误，不创建审批
+	before, _ := dao.ListApprovals("", 100)
+	texts, isError := callTool(t, srv.URL, alice.ID, "scale_workload", map[string]any{
+		"cluster_name": "test-bj", "namespace": "default", "kind": "Deployment", "name": "web", "replicas": -1,
+	})
+	if !isError || !strings.Contains(texts[0], "replicas 必须为") {
+		t.Errorf("invalid replicas should be rejected before approval, got %q", texts)
+	}
+	if after, _ := dao.ListApprovals("", 100); len(after) != len(before) {
+		t.Errorf("invalid call created an approval: %d -> %d", len(before), len(after))
+	}
+}
+
+func TestApprovalResultNotification(t *testing.T) {
+	openTestDB(t)
+	useRBACPolicyFile(t, "../config/rbac.yaml")
+	if err := dao.CreateCluster(&dao.Cluster{ClusterName: "test-bj", KubeConfig: testKubeConfig}); err != nil {
+		t.Fatalf("create cluster: %v", err)
+	}
+	s := mcp.NewMCPServer()
+	sm := mcp.NewHTTPSessionManager(mcp.NewMemorySessionStore(), time.Minute, s)
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", s.ServeHTTP())
+	s.RegisterApprovalHandlers(mux)
+	srv := httptest.NewServer(mcp.SessionMiddleware(sm, mux))
+	defer srv.Close()
+	requester, _ := sm.CreateSession("alice", "oncall")
+	approver, _ := sm.CreateSession("root", "admin")
+
+	// 发起人打开 GET 监听流
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/mcp", nil)
+	req.Header.Set(common.HeaderMcpSessionId, requester.ID)
+	stream, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	defer stream.Body.Close()
+	events := make(chan string, 10)
+	go func() {
+		scanner := bufio.NewScanner(stream.Body)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				events <- data
+			}
+		}
+	}()
+
+	texts, isError := callTool(t, srv.URL, requester.ID, "rollout_restart_deployment", map[string]any{
+		"cluster_name": "test-bj", "namespace": "default", "name": "web",
+	})
+	text := texts[0]
+	var pending struct {
+		ApprovalID uint   `json:"approval_id"`
+		Status     string `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(text), &pending); isError || err != nil || pending.Status != dao.ApprovalPending {
+		t.Fatalf("expected pending approval, got %s", text)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("%s/admin/approvals/%d/approve", srv.URL, pending.ApprovalID), nil)
+	req.Header.Set(common.HeaderMcpSessionId, approver.ID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("approve: got %d", resp.StatusCode)
+	}
+
+	select {
+	case data := <-events:
+		t.Logf("notification: %s", data)
+		var n struct {
+			Method string `json:"method"`
+			Params struct {
+				ApprovalID uint   `json:"approval_id"`
+				Approver   string `json:"approver"`
+			} `json:"params"`
+		}
+		if err := json.Unmarshal([]byte(data), &n); err != nil || n.Method != "notifications/approval" || n.Params.ApprovalID != pending.ApprovalID || n.Params.Approver != "root" {
+			t.Errorf("unexpected notification: %s", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("approval notification not received")
+	}
+	logs, err := dao.QueryAuditLogs(dao.AuditLogQuery{Tool: "rollout_restart_deployment", Limit: 10})
+	if err != nil {
+		t.Fatalf("query audit log: %v", err)
+	}
+	executed := false
+	for _, l := range logs {
+		if l.UserID == "alice" && l.Approver == "root" {
+			executed = true
+		}
+	}
+	if !executed {
+		t.Errorf("approved execution should be audited with requester and approver: %+v", logs)
+	}
+	logs, err = dao.QueryAuditLogs(dao.AuditLogQuery{Tool: "approve_request", UserID: "root", Limit: 10})
+	if err != nil {
+		t.Fatalf("query audit log: %v", err)
+	}
+	if len(logs) != 1 || logs[0].Outcome != dao.AuditSuccess || logs[0].ClusterName != "test-bj" {
+		t.Errorf("approval via HTTP should be audited: %+v", logs)
+	}
+}
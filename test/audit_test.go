@@ -0,0 +1,35 @@
+package test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/relaxyabc/k8s-helper/mcp"
+)
+
+func TestRedactParams(t *testing.T) {
+	params := map[string]any{
+		"cluster_name": "prod-bj",
+		"kube_config":  "apiVersion: v1\nusers: ...",
+		"options":      map[string]any{"Token": "abc", "replicas": 3},
+		"url":          "/clusters?cluster_name=prod&password=p%40ss",
+		"body":         `{"client_secret":"s3cr3t","name":"web"}`,
+	}
+	redacted := mcp.RedactParams(params)
+	data, _ := json.Marshal(redacted)
+	t.Logf("redacted=%s", data)
+	for _, leaked := range []string{"users:", "abc", "p%40ss", "s3cr3t"} {
+		if strings.Contains(string(data), leaked) {
+			t.Errorf("敏感信息 %q 未脱敏", leaked)
+		}
+	}
+	for _, kept := range []string{"prod-bj", `\"name\":\"web\"`, `"replicas":3`, "cluster_name=prod"} {
+		if !strings.Contains(string(data), kept) {
+			t.Errorf("非敏感信息 %q 不应被修改", kept)
+		}
+	}
+	if params["kube_config"] == "***" {
+		t.Error("不应修改原始参数")
+	}
+}
@@ -0,0 +1,57 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/relaxyabc/k8s-helper/dao"
+	"github.com/relaxyabc/k8s-helper/tools"
+)
+
+func TestInvalidateClusterClientClosesIdleConnections(t *testing.T) {
+	openTestDB(t)
+	var closed atomic.Int32
+	apiserver := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"major":"1","minor":"30","gitVersion":"v1.30.0"}`)
+	}))
+	apiserver.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed.Add(1)
+		}
+	}
+	apiserver.Start()
+	defer apiserver.Close()
+	kubeconfig := strings.Replace(testKubeConfig, "https://127.0.0.1:1", apiserver.URL, 1)
+	if err := dao.CreateCluster(&dao.Cluster{ClusterName: "dev", KubeConfig: kubeconfig}); err != nil {
+		t.Fatalf("create cluster: %v", err)
+	}
+
+	clientset, err := tools.GetClusterClientset("", "dev")
+	if err != nil {
+		t.Fatalf("get clientset: %v", err)
+	}
+	if _, err := clientset.Discovery().RESTClient().Get().AbsPath("/version").Do(context.Background()).Raw(); err != nil {
+		t.Fatalf("get version: %v", err)
+	}
+	if closed.Load() != 0 {
+		t.Fatal("connection should stay open for reuse")
+	}
+
+	// 丢弃缓存的 client 时关闭其空闲连接
+	tools.InvalidateClusterClient("dev")
+	deadline := time.Now().Add(5 * time.Second)
+	for closed.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if closed.Load() == 0 {
+		t.Error("idle connection of the invalidated client should be closed")
+	}
+}
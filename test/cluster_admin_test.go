@@ -0,0 +1,199 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relaxyabc/k8s-helper/common"
+	"github.com/relaxyabc/k8s-helper/crypto"
+	"github.com/relaxyabc/k8s-helper/dao"
+	"github.com/relaxyabc/k8s-helper/mcp"
+)
+
+// fakeAPIServerKubeConfig 启动只响应 /version 的假 apiserver，返回指向它的 kubeconfig
+func fakeAPIServerKubeConfig(t *testing.T, token string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"major":"1","minor":"30","gitVersion":"v1.30.0"}`)
+	}))
+	t.Cleanup(srv.Close)
+	kubeconfig := strings.Replace(testKubeConfig, "https://127.0.0.1:1", srv.URL, 1)
+	return strings.Replace(kubeconfig, "test-token", token, 1)
+}
+
+func TestClusterAdminHandlers(t *testing.T) {
+	openTestDB(t)
+	keys, _ := crypto.ParseKeyRing("k1:" + testMasterKey('k'))
+	dao.SetKubeConfigKeyRing(keys)
+	defer dao.SetKubeConfigKeyRing(nil)
+	s := mcp.NewMCPServer()
+	sm := mcp.NewHTTPSessionManager(mcp.NewMemorySessionStore(), time.Minute, s)
+	mux := http.NewServeMux()
+	s.RegisterClusterHandlers(mux)
+	s.RegisterApprovalHandlers(mux)
+	handler := mcp.SessionMiddleware(sm, mux)
+	admin, _ := sm.CreateSession("root", "admin")
+	approver, _ := sm.CreateSession("alice", "admin")
+	guest, _ := sm.CreateSession("frank", "guest")
+
+	do := func(method, path, sid string, body any) *httptest.ResponseRecorder {
+		var r io.Reader
+		if body != nil {
+			data, _ := json.Marshal(body)
+			r = bytes.NewReader(data)
+		}
+		req := httptest.NewRequest(method, path, r)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(common.HeaderMcpSessionId, sid)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		t.Logf("%s %s -> %d %s", method, path, rec.Code, rec.Body.String())
+		return rec
+	}
+	storedKubeConfig := func(name string) string {
+		var raw string
+		dao.GetDB().Unscoped().Model(&dao.Cluster{}).Where("cluster_name = ?", name).Select("kube_config").Scan(&raw)
+		return raw
+	}
+	first := fakeAPIServerKubeConfig(t, "first-token")
+	second := fakeAPIServerKubeConfig(t, "second-token")
+
+	if rec := do(http.MethodPost, "/admin/clusters", guest.ID, map[string]any{"cluster_name": "dev", "kube_config": first}); rec.Code != http.StatusForbidden {
+		t.Errorf("guest register: got %d", rec.Code)
+	}
+
+	// 注册：连通性检查通过，kubeconfig 加密存储，读取时解密
+	rec := do(http.MethodPost, "/admin/clusters", admin.ID, map[string]any{"cluster_name": "dev", "kube_config": first, "ip": "10.0.0.1"})
+	if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), "v1.30.0") || strings.Contains(rec.Body.String(), "first-token") {
+		t.Fatalf("register: got %d %s", rec.Code, rec.Body.String())
+	}
+	if raw := storedKubeConfig("dev"); !crypto.IsEncrypted(raw) || strings.Contains(raw, "first-token") {
+		t.Errorf("kubeconfig should be stored encrypted, got %q", raw)
+	}
+	if got, err := dao.GetKubeConfig("dev"); err != nil || got != first {
+		t.Errorf("kubeconfig round trip: %v", err)
+	}
+	if rec := do(http.MethodPost, "/admin/clusters", admin.ID, map[string]any{"cluster_name": "dev", "kube_config": first}); rec.Code != http.StatusBadRequest {
+		t.Errorf("register existing: got %d", rec.Code)
+	}
+
+	// 审批：返回 202 和待审批记录，批准后执行
+	approve := func(rec *httptest.ResponseRecorder) {
+		t.Helper()
+		var pending struct {
+			ApprovalID uint `json:"approval_id"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &pending); rec.Code != http.StatusAccepted || err != nil {
+			t.Fatalf("expected a pending approval, got %d %s", rec.Code, rec.Body.String())
+		}
+		if rec := do(http.MethodPost, fmt.Sprintf("/admin/approvals/%d/approve", pending.ApprovalID), approver.ID, nil); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), dao.ApprovalExecuted) {
+			t.Fatalf("approve: got %d %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	// 更新：只修改传入的字段，新的 kubeconfig 同样加密
+	if rec := do(http.MethodPut, "/admin/clusters/dev", admin.ID, map[string]any{"kube_config": second}); rec.Code != http.StatusOK {
+		t.Fatalf("update kubeconfig: got %d", rec.Code)
+	}
+	if raw := storedKubeConfig("dev"); !crypto.IsEncrypted(raw) {
+		t.Errorf("updated kubeconfig should be stored encrypted, got %q", raw)
+	}
+	if got, _ := dao.GetKubeConfig("dev"); got != second {
+		t.Error("updated kubeconfig round trip failed")
+	}
+
+	// 修改 protected 需要审批，审批前不生效
+	rec = do(http.MethodPut, "/admin/clusters/dev", admin.ID, map[string]any{"protected": true})
+	if c, _ := dao.GetCluster("dev"); c.Protected {
+		t.Error("protected should not change before approval")
+	}
+	approve(rec)
+	if c, err := dao.GetCluster("dev"); err != nil || !c.Protected || c.IP != "10.0.0.1" {
+		t.Errorf("update should only change protected: %+v, %v", c, err)
+	}
+
+	// 受保护集群的任何更新都需要审批，审批记录中的 kubeconfig 不对外返回
+	if rec := do(http.MethodPut, "/admin/clusters/dev", admin.ID, map[string]any{"kube_config": first}); rec.Code != http.StatusAccepted {
+		t.Errorf("update protected cluster: got %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/admin/approvals?status=pending", admin.ID, nil); strings.Contains(rec.Body.String(), "first-token") {
+		t.Errorf("approval arguments should be redacted: %s", rec.Body.String())
+	}
+	if got, _ := dao.GetKubeConfig("dev"); got != second {
+		t.Error("kubeconfig of a protected cluster should not change before approval")
+	}
+
+	// 删除：受保护集群需要审批，软删除后不可见，再次删除或更新返回 404
+	approve(do(http.MethodDelete, "/admin/clusters/dev", admin.ID, nil))
+	if rec := do(http.MethodGet, "/admin/clusters", admin.ID, nil); strings.Contains(rec.Body.String(), `"dev"`) {
+		t.Errorf("deleted cluster should not be listed: %s", rec.Body.String())
+	}
+	if rec := do(http.MethodDelete, "/admin/clusters/dev", admin.ID, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("delete again: got %d", rec.Code)
+	}
+	if rec := do(http.MethodPut, "/admin/clusters/dev", admin.ID, map[string]any{"ip": "10.0.0.2"}); rec.Code != http.StatusNotFound {
+		t.Errorf("update deleted: got %d", rec.Code)
+	}
+
+	// 恢复：重新注册同名集群覆盖已删除的记录
+	if rec := do(http.MethodPost, "/admin/clusters", admin.ID, map[string]any{"cluster_name": "dev", "kube_config": first}); rec.Code != http.StatusCreated {
+		t.Fatalf("restore: got %d", rec.Code)
+	}
+	if c, err := dao.GetCluster("dev"); err != nil || c.Protected || c.IP != "" {
+		t.Errorf("restored cluster should take the new settings: %+v, %v", c, err)
+	}
+	if got, _ := dao.GetKubeConfig("dev"); got != first {
+		t.Error("restored kubeconfig round trip failed")
+	}
+
+	if logs, _ := dao.QueryAuditLogs(dao.AuditLogQuery{Tool: "register_cluster", ClusterName: "dev", Limit: 10}); len(logs) != 3 {
+		t.Errorf("register calls should be audited, got %d rows", len(logs))
+	}
+	if logs, _ := dao.QueryAuditLogs(dao.AuditLogQuery{UserID: "frank", Limit: 10}); len(logs) != 1 || logs[0].Outcome != dao.AuditError {
+		t.Errorf("denied register should be audited: %+v", logs)
+	}
+}
+
+func TestRegisterClusterRequestTooLarge(t *testing.T) {
+	openTestDB(t)
+	s := mcp.NewMCPServer()
+	sm := mcp.NewHTTPSessionManager(mcp.NewMemorySessionStore(), time.Minute, s)
+	mux := http.NewServeMux()
+	s.RegisterClusterHandlers(mux)
+	handler := mcp.SessionMiddleware(sm, mux)
+	admin, _ := sm.CreateSession("root", "admin")
+	huge := strings.Repeat("x", 2<<20)
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	mw.WriteField("cluster_name", "dev")
+	fw, _ := mw.CreateFormFile("kubeconfig", "config")
+	fw.Write([]byte(huge))
+	mw.Close()
+	bodies := map[string]struct {
+		contentType string
+		body        string
+	}{
+		"multipart": {mw.FormDataContentType(), form.String()},
+		"json":      {"application/json", `{"cluster_name":"dev","kubeconfig":"` + huge + `"}`},
+	}
+	for name, b := range bodies {
+		req := httptest.NewRequest(http.MethodPost, "/admin/clusters", strings.NewReader(b.body))
+		req.Header.Set("Content-Type", b.contentType)
+		req.Header.Set(common.HeaderMcpSessionId, admin.ID)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: got %d %s, want 413", name, rec.Code, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/relaxyabc/k8s-helper/tools"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNormalizeDescribeKind(t *testing.T) {
+	for in, want := range map[string]string{"deploy": "Deployment", "STS": "StatefulSet", "svc": "Service", "Pod": "Pod"} {
+		got, err := tools.NormalizeDescribeKind(in)
+		if err != nil || got != want {
+			t.Errorf("%s: got %s (%v), want %s", in, got, err, want)
+		}
+	}
+	if _, err := tools.NormalizeDescribeKind("CronJob"); err == nil {
+		t.Error("不支持的类型应返回错误")
+	}
+}
+
+func TestDescribeDeployment(t *testing.T) {
+	replicas := int32(3)
+	d := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Annotations: map[string]string{"deployment.kubernetes.io/revision": "4"}},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			Strategy: appsv1.DeploymentStrategy{Type: appsv1.RollingUpdateDeploymentStrategyType},
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name:  "web",
+				Image: "nginx:1.27",
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+					Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+				},
+			}}}},
+		},
+		Status: appsv1.DeploymentStatus{Replicas: 3, ReadyReplicas: 2, UpdatedReplicas: 3, AvailableReplicas: 2,
+			Conditions: []appsv1.DeploymentCondition{{Type: appsv1.DeploymentAvailable, Status: corev1.ConditionFalse, Reason: "MinimumReplicasUnavailable"}}},
+	}
+	desc := tools.DescribeDeployment(d)
+	out, _ := json.Marshal(desc)
+	t.Logf("describe:\n%s", out)
+	if desc.Selector != "app=web" || desc.Replicas.Desired != 3 || desc.Replicas.Ready != 2 {
+		t.Errorf("unexpected selector/replicas: %s %+v", desc.Selector, desc.Replicas)
+	}
+	if desc.Containers[0].Requests["cpu"] != "100m" || desc.Containers[0].Limits["memory"] != "256Mi" {
+		t.Errorf("unexpected resources: %+v", desc.Containers[0])
+	}
+	if len(desc.Conditions) != 1 || desc.Spec["revision"] != "4" {
+		t.Errorf("unexpected conditions/spec: %+v %+v", desc.Conditions, desc.Spec)
+	}
+}
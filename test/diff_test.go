@@ -0,0 +1,59 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/relaxyabc/k8s-helper/tools"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	from := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\n"
+	to := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\no\n"
+	got := tools.UnifiedDiff("a/x", "b/x", from, to)
+	t.Logf("diff:\n%s", got)
+	want := `--- a/x
++++ b/x
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -12,3 +12,4 @@
+ l
+ m
+ n
++o
+`
+	if got != want {
+		t.Errorf("unexpected diff:\n%s", got)
+	}
+	if tools.UnifiedDiff("a/x", "b/x", from, from) != "" {
+		t.Error("相同内容应返回空 diff")
+	}
+	if got := tools.UnifiedDiff("a/x", "b/x", "", "a\n"); !strings.Contains(got, "@@ -0,0 +1,1 @@\n+a\n") {
+		t.Errorf("unexpected diff for new file:\n%s", got)
+	}
+}
+
+func TestObjectDiff(t *testing.T) {
+	before := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Name:          "web",
+		ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+	}}
+	after := before.DeepCopy()
+	after.Spec.Template.Annotations = map[string]string{"kubectl.kubernetes.io/restartedAt": "2025-01-01T00:00:00Z"}
+	after.ManagedFields = append(after.ManagedFields, metav1.ManagedFieldsEntry{Manager: "k8s-helper"})
+	diff, err := tools.ObjectDiff("deployment/web", before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("diff:\n%s", diff)
+	if !strings.Contains(diff, "+      annotations:") || strings.Contains(diff, "manager") {
+		t.Errorf("unexpected object diff:\n%s", diff)
+	}
+}
@@ -0,0 +1,80 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/relaxyabc/k8s-helper/tools"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newFakeDiscovery() *fakediscovery.FakeDiscovery {
+	return &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "pods", SingularName: "pod", Kind: "Pod", Namespaced: true, ShortNames: []string{"po"}, Verbs: []string{"get", "list"}},
+			{Name: "nodes", SingularName: "node", Kind: "Node", ShortNames: []string{"no"}, Verbs: []string{"get", "list"}},
+		}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+			{Name: "statefulsets", SingularName: "statefulset", Kind: "StatefulSet", Namespaced: true, ShortNames: []string{"sts"}, Verbs: []string{"get", "list"}},
+		}},
+		{GroupVersion: "networking.k8s.io/v1", APIResources: []metav1.APIResource{
+			{Name: "ingresses", SingularName: "ingress", Kind: "Ingress", Namespaced: true, ShortNames: []string{"ing"}, Verbs: []string{"get", "list"}},
+		}},
+		{GroupVersion: "cert-manager.io/v1", APIResources: []metav1.APIResource{
+			{Name: "certificates", SingularName: "certificate", Kind: "Certificate", Namespaced: true, ShortNames: []string{"cert"}, Verbs: []string{"get", "list"}},
+		}},
+	}}}
+}
+
+func TestResolveResource(t *testing.T) {
+	mapper := tools.NewResourceMapper(newFakeDiscovery())
+	cases := []struct {
+		resource, apiVersion, want string
+		namespaced                 bool
+	}{
+		{"sts", "", "apps/v1, Resource=statefulsets", true},
+		{"ing", "", "networking.k8s.io/v1, Resource=ingresses", true},
+		{"Pod", "", "/v1, Resource=pods", true},
+		{"nodes", "", "/v1, Resource=nodes", false},
+		{"Certificate", "", "cert-manager.io/v1, Resource=certificates", true},
+		{"certificates.cert-manager.io", "", "cert-manager.io/v1, Resource=certificates", true},
+		{"statefulsets.v1.apps", "", "apps/v1, Resource=statefulsets", true},
+		{"ingress", "networking.k8s.io/v1", "networking.k8s.io/v1, Resource=ingresses", true},
+	}
+	for _, c := range cases {
+		mapping, err := tools.ResolveResource(mapper, c.resource, c.apiVersion)
+		if err != nil {
+			t.Errorf("%s: %v", c.resource, err)
+			continue
+		}
+		got := mapping.Resource.String()
+		t.Logf("%s -> %s, scope=%s", c.resource, got, mapping.Scope.Name())
+		if got != c.want || (mapping.Scope.Name() == "namespace") != c.namespaced {
+			t.Errorf("%s: got %s scope %s, want %s", c.resource, got, mapping.Scope.Name(), c.want)
+		}
+	}
+	if _, err := tools.ResolveResource(mapper, "widgets", ""); err == nil {
+		t.Error("未知资源应返回错误")
+	}
+}
+
+func TestEncodeResource(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]any{"apiVersion": "v1", "kind": "Pod"}}
+	obj.SetName("web")
+	obj.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: "kubectl"}})
+	tools.StripManagedFields(obj)
+	out, err := tools.EncodeResource(obj.UnstructuredContent(), tools.OutputYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("encode:\n%s", out)
+	if strings.Contains(out, "managedFields") || !strings.Contains(out, "name: web") {
+		t.Errorf("unexpected yaml: %s", out)
+	}
+	if _, err := tools.EncodeResource(obj.UnstructuredContent(), "xml"); err == nil {
+		t.Error("不支持的格式应返回错误")
+	}
+}
@@ -0,0 +1,76 @@
+package test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/relaxyabc/k8s-helper/crypto"
+)
+
+func testMasterKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32)))
+}
+
+func TestEnvelopeEncryptDecrypt(t *testing.T) {
+	keys, err := crypto.ParseKeyRing("# 当前主密钥\nk1:" + testMasterKey('a'))
+	if err != nil {
+		t.Fatalf("parse key ring: %v", err)
+	}
+	plain := "apiVersion: v1\nkind: Config\n"
+	enc, err := keys.Encrypt([]byte(plain), []byte("dev"))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	t.Logf("encrypted=%s", enc)
+	if !crypto.IsEncrypted(enc) || !strings.HasPrefix(enc, crypto.EnvelopePrefix+"k1:") {
+		t.Fatalf("unexpected envelope: %s", enc)
+	}
+	got, err := keys.Decrypt(enc, []byte("dev"))
+	if err != nil || string(got) != plain {
+		t.Fatalf("decrypt: %q, %v", got, err)
+	}
+	if _, err := keys.Decrypt(enc, []byte("prod")); err == nil {
+		t.Error("decrypt with another cluster name should fail")
+	}
+	tampered := enc[:len(enc)-2] + "AA"
+	if _, err := keys.Decrypt(tampered, []byte("dev")); err == nil {
+		t.Error("decrypt tampered envelope should fail")
+	}
+}
+
+func TestEnvelopeKeyRotation(t *testing.T) {
+	oldKeys, _ := crypto.ParseKeyRing("k1:" + testMasterKey('a'))
+	enc, err := oldKeys.Encrypt([]byte("secret"), []byte("dev"))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	newKeys, err := crypto.ParseKeyRing("k2:" + testMasterKey('b') + ",k1:" + testMasterKey('a'))
+	if err != nil {
+		t.Fatalf("parse key ring: %v", err)
+	}
+	if !newKeys.NeedsRotation(enc) || !newKeys.NeedsRotation("plain kubeconfig") {
+		t.Error("old envelope and plaintext should need rotation")
+	}
+	got, err := newKeys.Decrypt(enc, []byte("dev"))
+	if err != nil || string(got) != "secret" {
+		t.Fatalf("decrypt with rotated key ring: %q, %v", got, err)
+	}
+	rotated, _ := newKeys.Encrypt(got, []byte("dev"))
+	if newKeys.NeedsRotation(rotated) {
+		t.Error("envelope with primary key should not need rotation")
+	}
+	if _, err := oldKeys.Decrypt(rotated, []byte("dev")); err == nil {
+		t.Error("old key ring should not decrypt envelope of new key")
+	}
+}
+
+func TestParseKeyRingInvalid(t *testing.T) {
+	for _, data := range []string{"", "k1", "k1:short", "k:1:" + testMasterKey('a'), "k1:" + testMasterKey('a') + ",k1:" + testMasterKey('b')} {
+		if _, err := crypto.ParseKeyRing(data); err == nil {
+			t.Errorf("ParseKeyRing(%q) should fail", data)
+		} else {
+			t.Logf("%q -> %v", data, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/relaxyabc/k8s-helper/tools"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSummarizeEvents(t *testing.T) {
+	now := time.Now()
+	pod := corev1.ObjectReference{Kind: "Pod", Name: "web-1"}
+	newEvent := func(typ, reason, msg string, count int32, last time.Time) corev1.Event {
+		return corev1.Event{
+			InvolvedObject: pod,
+			Type:           typ,
+			Reason:         reason,
+			Message:        msg,
+			Count:          count,
+			FirstTimestamp: metav1.NewTime(last.Add(-time.Minute)),
+			LastTimestamp:  metav1.NewTime(last),
+		}
+	}
+	events := []corev1.Event{
+		newEvent("Normal", "Pulled", "image pulled", 1, now.Add(-10*time.Minute)),
+		newEvent("Warning", "BackOff", "back-off restarting (old)", 3, now.Add(-5*time.Minute)),
+		newEvent("Warning", "BackOff", "back-off restarting (new)", 2, now.Add(-time.Minute)),
+		newEvent("Warning", "Unhealthy", "readiness probe failed", 4, now.Add(-2*time.Minute)),
+	}
+
+	all := tools.SummarizeEvents(events, "", 0)
+	for _, e := range all {
+		t.Logf("event: %+v", e)
+	}
+	if len(all) != 3 {
+		t.Fatalf("去重后应有 3 条，实际 %d", len(all))
+	}
+	if all[0].Reason != "BackOff" || all[0].Count != 5 || all[0].Message != "back-off restarting (new)" {
+		t.Errorf("最近的事件应为合并后的 BackOff: %+v", all[0])
+	}
+
+	warnings := tools.SummarizeEvents(events, "Warning", 1)
+	if len(warnings) != 1 || warnings[0].Type != "Warning" {
+		t.Errorf("类型过滤或条数限制无效: %+v", warnings)
+	}
+}
@@ -0,0 +1,112 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/relaxyabc/k8s-helper/common"
+	"github.com/relaxyabc/k8s-helper/dao"
+	"github.com/relaxyabc/k8s-helper/mcp"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testKubeConfig 指向不可达地址的 kubeconfig，连接集群的操作会快速失败
+const testKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: https://127.0.0.1:1
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+current-context: test
+users:
+- name: test
+  user:
+    token: test-token
+`
+
+// openTestDB 使用临时 sqlite 数据库初始化 dao
+func openTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := dao.InitDB(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+// useRBACPolicyFile 在测试期间使用指定的策略文件
+func useRBACPolicyFile(t *testing.T, file string) {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("read policy: %v", err)
+	}
+	useRBACPolicy(t, string(data))
+}
+
+// useRBACPolicy 在测试期间使用指定内容的策略
+func useRBACPolicy(t *testing.T, policy string) {
+	t.Helper()
+	p, err := mcp.ParseRBACPolicy([]byte(policy))
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	prev := mcp.SetRBACPolicy(p)
+	t.Cleanup(func() { mcp.SetRBACPolicy(prev) })
+}
+
+// callTool 以指定会话通过 /mcp 调用工具，返回各段文本结果和是否为错误结果
+func callTool(t *testing.T, baseURL, sid, name string, args map[string]any) ([]string, bool) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params":  map[string]any{"name": name, "arguments": args},
+	})
+	req, _ := http.NewRequest(http.MethodPost, baseURL+"/mcp", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(common.HeaderMcpSessionId, sid)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("call %s: %v", name, err)
+	}
+	defer resp.Body.Close()
+	var rpc struct {
+		Result struct {
+			Content []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+			IsError bool `json:"isError"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rpc); err != nil {
+		t.Fatalf("decode %s result: %v", name, err)
+	}
+	if len(rpc.Result.Content) == 0 {
+		t.Fatalf("%s returned no content", name)
+	}
+	texts := make([]string, 0, len(rpc.Result.Content))
+	for _, c := range rpc.Result.Content {
+		texts = append(texts, c.Text)
+	}
+	return texts, rpc.Result.IsError
+}
@@ -0,0 +1,86 @@
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relaxyabc/k8s-helper/common"
+	"github.com/relaxyabc/k8s-helper/dao"
+	"github.com/relaxyabc/k8s-helper/mcp"
+)
+
+func TestFollowPodLogsReadError(t *testing.T) {
+	openTestDB(t)
+	// 假 apiserver 返回一行超过 1MB 的日志
+	apiserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/log") {
+			fmt.Fprintln(w, "first line")
+			fmt.Fprintln(w, strings.Repeat("x", 2<<20))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"major":"1","minor":"30","gitVersion":"v1.30.0"}`)
+	}))
+	defer apiserver.Close()
+	kubeconfig := strings.Replace(testKubeConfig, "https://127.0.0.1:1", apiserver.URL, 1)
+	if err := dao.CreateCluster(&dao.Cluster{ClusterName: "test-bj", KubeConfig: kubeconfig}); err != nil {
+		t.Fatalf("create cluster: %v", err)
+	}
+	s := mcp.NewMCPServer()
+	s.RegisterSSEPushTool(nil)
+	sm := mcp.NewHTTPSessionManager(mcp.NewMemorySessionStore(), time.Minute, s)
+	srv := httptest.NewServer(mcp.SessionMiddleware(sm, s.ServeHTTP()))
+	defer srv.Close()
+	ses, _ := sm.CreateSession("root", "admin")
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/mcp", nil)
+	req.Header.Set(common.HeaderMcpSessionId, ses.ID)
+	stream, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	defer stream.Body.Close()
+	events := make(chan string, 10)
+	go func() {
+		scanner := bufio.NewScanner(stream.Body)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				events <- data
+			}
+		}
+	}()
+
+	texts, isError := callTool(t, srv.URL, ses.ID, "follow_pod_logs", map[string]any{
+		"cluster_name": "test-bj", "namespace": "default", "name": "web", "timeout_seconds": 30,
+	})
+	if isError {
+		t.Fatalf("follow_pod_logs: %q", texts)
+	}
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case data := <-events:
+			var msg struct {
+				Params struct {
+					Done  bool   `json:"done"`
+					Cause string `json:"cause"`
+				} `json:"params"`
+			}
+			if err := json.Unmarshal([]byte(data), &msg); err != nil || !msg.Params.Done {
+				continue
+			}
+			if msg.Params.Cause != "error" {
+				t.Errorf("read error should end the follow with cause error, got %s", data)
+			}
+			return
+		case <-timeout:
+			t.Fatal("no done notification")
+		}
+	}
+}
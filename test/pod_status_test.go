@@ -0,0 +1,73 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/relaxyabc/k8s-helper/tools"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSummarizePod(t *testing.T) {
+	now := time.Now()
+	controller := true
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "web-5d8f9c-abcde",
+			CreationTimestamp: metav1.NewTime(now.Add(-3 * time.Hour)),
+			OwnerReferences:   []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-5d8f9c", Controller: &controller}},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:   "node-1",
+			Containers: []corev1.Container{{Name: "web"}, {Name: "sidecar"}},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			PodIP: "10.0.0.12",
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name:                 "web",
+					RestartCount:         7,
+					State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+					LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}},
+				},
+				{Name: "sidecar", Ready: true, State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+			},
+		},
+	}
+	ps := tools.SummarizePod(pod, now)
+	t.Logf("pod status: %+v", ps)
+	if ps.Status != "CrashLoopBackOff" {
+		t.Errorf("status: got %s, want CrashLoopBackOff", ps.Status)
+	}
+	if ps.Ready != "1/2" || ps.Restarts != 7 {
+		t.Errorf("ready/restarts: got %s/%d", ps.Ready, ps.Restarts)
+	}
+	if ps.LastTerminationReason != "OOMKilled" || ps.Owner != "ReplicaSet/web-5d8f9c" || ps.Age != "3h" {
+		t.Errorf("unexpected summary: %+v", ps)
+	}
+}
+
+func TestSummarizePodInitializing(t *testing.T) {
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "migrate"}},
+			Containers:     []corev1.Container{{Name: "app"}},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			InitContainerStatuses: []corev1.ContainerStatus{
+				{Name: "migrate", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 1}}},
+			},
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "app", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "PodInitializing"}}},
+			},
+		},
+	}
+	ps := tools.SummarizePod(pod, time.Now())
+	t.Logf("pod status: %+v", ps)
+	if ps.Status != "Init:Error" {
+		t.Errorf("status: got %s, want Init:Error", ps.Status)
+	}
+}
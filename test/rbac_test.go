@@ -0,0 +1,192 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/relaxyabc/k8s-helper/common"
+	"github.com/relaxyabc/k8s-helper/mcp"
+)
+
+func TestParseRBACPolicy(t *testing.T) {
+	data := []byte(`
+roles:
+  oncall:
+    tools: ["get_*", rollout_restart_deployment]
+    clusters: ["prod-*"]
+    namespaces: ["app-?", default]
+`)
+	p, err := mcp.ParseRBACPolicy(data)
+	if err != nil {
+		t.Fatalf("解析策略失败: %v", err)
+	}
+	cases := []struct {
+		kind, value string
+		want        bool
+	}{
+		{"tool", "get_pods", true},
+		{"tool", "rollout_restart_deployment", true},
+		{"tool", "rollout_restart_daemonset", false},
+		{"cluster", "prod-bj", true},
+		{"cluster", "test-bj", false},
+		{"namespace", "app-1", true},
+		{"namespace", "app-10", false},
+		{"namespace", "default", true},
+	}
+	for _, c := range cases {
+		var got bool
+		switch c.kind {
+		case "tool":
+			got = p.AllowTool("oncall", c.value)
+		case "cluster":
+			got = p.AllowCluster("oncall", c.value)
+		case "namespace":
+			got = p.AllowNamespace("oncall", c.value)
+		}
+		t.Logf("%s=%s allowed=%v", c.kind, c.value, got)
+		if got != c.want {
+			t.Errorf("%s %s: got %v, want %v", c.kind, c.value, got, c.want)
+		}
+	}
+	if p.AllowTool("unknown", "get_pods") {
+		t.Error("未定义的角色不应有任何权限")
+	}
+}
+
+func TestParseRBACPolicyInvalid(t *testing.T) {
+	if _, err := mcp.ParseRBACPolicy([]byte(`roles: {bad: {tools: ["[a-"]}}`)); err == nil {
+		t.Error("非法通配表达式应返回错误")
+	}
+	if _, err := mcp.ParseRBACPolicy([]byte(`{"roles": {}}`)); err == nil {
+		t.Error("空策略应返回错误")
+	}
+}
+
+func TestDefaultRBACPolicy(t *testing.T) {
+	if !mcp.IsToolAllowed("admin", "rollout_restart_deployment") {
+		t.Error("admin 应允许所有工具")
+	}
+	if mcp.IsToolAllowed("guest", "rollout_restart_deployment") {
+		t.Error("guest 不应允许 rollout_restart_deployment")
+	}
+	if mcp.IsToolAllowed("", "get_clusters") {
+		t.Error("空角色不应允许任何工具")
+	}
+	for _, role := range []string{"user", "guest"} {
+		if mcp.IsToolAllowed(role, "scale_workload") {
+			t.Errorf("%s 不应允许 scale_workload", role)
+		}
+	}
+}
+
+func TestProtectedNamespaceMinReplicas(t *testing.T) {
+	p, err := mcp.ParseRBACPolicy([]byte(`
+roles:
+  admin: {tools: ["*"], clusters: ["*"], namespaces: ["*"]}
+protected_namespaces:
+  - namespaces: [kube-system, "prod-*"]
+    min_replicas: 1
+  - clusters: ["prod-*"]
+    namespaces: [prod-payment]
+    min_replicas: 3
+`))
+	if err != nil {
+		t.Fatalf("解析策略失败: %v", err)
+	}
+	cases := []struct {
+		cluster, namespace string
+		want               int32
+	}{
+		{"test-bj", "kube-system", 1},
+		{"test-bj", "prod-payment", 1},
+		{"prod-bj", "prod-payment", 3},
+		{"prod-bj", "default", 0},
+	}
+	for _, c := range cases {
+		got := p.MinReplicas(c.cluster, c.namespace)
+		t.Logf("cluster=%s namespace=%s min_replicas=%d", c.cluster, c.namespace, got)
+		if got != c.want {
+			t.Errorf("%s/%s: got %d, want %d", c.cluster, c.namespace, got, c.want)
+		}
+	}
+	if _, err := mcp.ParseRBACPolicy([]byte(`{"roles": {"a": {}}, "protected_namespaces": [{"namespaces": ["x"], "min_replicas": -1}]}`)); err == nil {
+		t.Error("负数 min_replicas 应返回错误")
+	}
+}
+
+func TestExampleRBACPolicyFile(t *testing.T) {
+	data, err := os.ReadFile("../config/rbac.yaml")
+	if err != nil {
+		t.Fatalf("读取示例策略失败: %v", err)
+	}
+	p, err := mcp.ParseRBACPolicy(data)
+	if err != nil {
+		t.Fatalf("示例策略非法: %v", err)
+	}
+	t.Logf("example policy roles=%d", len(p.Roles))
+
+	// 示例策略与内置策略的非 admin 角色一致
+	for _, tool := range listToolNames(t) {
+		for _, role := range []string{"user", "guest"} {
+			if got, want := p.AllowTool(role, tool), mcp.IsToolAllowed(role, tool); got != want {
+				t.Errorf("%s %s: example allows=%v, built-in allows=%v", role, tool, got, want)
+			}
+		}
+	}
+	for _, role := range []string{"user", "oncall", "readonly-prod"} {
+		for _, tool := range []string{"use_cluster", "use_namespace"} {
+			if !p.AllowTool(role, tool) {
+				t.Errorf("example policy should allow %s to use %s", role, tool)
+			}
+		}
+	}
+	// 只读角色不能通过通用查询工具读取 Secret
+	for _, tool := range []string{"get_resource", "list_resources"} {
+		if p.AllowTool("readonly-prod", tool) {
+			t.Errorf("readonly-prod should not be allowed to use %s", tool)
+		}
+	}
+}
+
+// listToolNames 以 admin 会话通过 tools/list 查询全部工具名
+func listToolNames(t *testing.T) []string {
+	t.Helper()
+	s := mcp.NewMCPServer()
+	sm := mcp.NewHTTPSessionManager(mcp.NewMemorySessionStore(), time.Minute, s)
+	srv := httptest.NewServer(mcp.SessionMiddleware(sm, s.ServeHTTP()))
+	defer srv.Close()
+	admin, _ := sm.CreateSession("root", "admin")
+
+	body, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "tools/list"})
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/mcp", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(common.HeaderMcpSessionId, admin.ID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("list tools: %v", err)
+	}
+	defer resp.Body.Close()
+	var rpc struct {
+		Result struct {
+			Tools []struct {
+				Name string `json:"name"`
+			} `json:"tools"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rpc); err != nil {
+		t.Fatalf("decode tools: %v", err)
+	}
+	names := make([]string, 0, len(rpc.Result.Tools))
+	for _, tool := range rpc.Result.Tools {
+		names = append(names, tool.Name)
+	}
+	if len(names) == 0 {
+		t.Fatal("no tools listed")
+	}
+	return names
+}
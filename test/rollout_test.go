@@ -0,0 +1,81 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/relaxyabc/k8s-helper/tools"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestDeploymentRolloutStatus(t *testing.T) {
+	replicas := int32(3)
+	cases := []struct {
+		name   string
+		status appsv1.DeploymentStatus
+		gen    int64
+		done   bool
+		failed bool
+		want   string
+	}{
+		{"spec not observed", appsv1.DeploymentStatus{ObservedGeneration: 1}, 2, false, false, "spec update"},
+		{"updating", appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 1}, 2, false, false, "1 out of 3 new replicas"},
+		{"old pending", appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 4, UpdatedReplicas: 3}, 2, false, false, "1 old replicas"},
+		{"unavailable", appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 3, AvailableReplicas: 2}, 2, false, false, "2 of 3 updated"},
+		{"done", appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 3, AvailableReplicas: 3}, 2, true, false, "successfully rolled out"},
+		{"deadline", appsv1.DeploymentStatus{ObservedGeneration: 2, Conditions: []appsv1.DeploymentCondition{
+			{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: "ProgressDeadlineExceeded"},
+		}}, 2, false, true, "progress deadline"},
+	}
+	for _, c := range cases {
+		d := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Generation: c.gen},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			Status:     c.status,
+		}
+		msg, done, err := tools.DeploymentRolloutStatus(d)
+		if err != nil {
+			msg = err.Error()
+		}
+		t.Logf("%s: done=%v %s", c.name, done, msg)
+		if done != c.done || (err != nil) != c.failed || !strings.Contains(msg, c.want) {
+			t.Errorf("%s: got done=%v err=%v msg=%q", c.name, done, err, msg)
+		}
+	}
+}
+
+func TestDeploymentRevisions(t *testing.T) {
+	d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Name: "web", UID: types.UID("uid-web"),
+		Annotations: map[string]string{"deployment.kubernetes.io/revision": "3"},
+	}}
+	newRS := func(name, revision, image, cause string, owner types.UID) appsv1.ReplicaSet {
+		controller := true
+		return appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				Annotations:     map[string]string{"deployment.kubernetes.io/revision": revision, "kubernetes.io/change-cause": cause},
+				OwnerReferences: []metav1.OwnerReference{{UID: owner, Controller: &controller}},
+			},
+			Spec: appsv1.ReplicaSetSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Image: image}}}}},
+		}
+	}
+	revisions := tools.DeploymentRevisions(d, []appsv1.ReplicaSet{
+		newRS("web-c", "3", "nginx:1.27", "upgrade to 1.27", "uid-web"),
+		newRS("web-a", "1", "nginx:1.25", "", "uid-web"),
+		newRS("other", "2", "redis:7", "", "uid-other"),
+		newRS("web-b", "2", "nginx:1.26", "upgrade to 1.26", "uid-web"),
+	})
+	for _, r := range revisions {
+		t.Logf("revision=%d rs=%s images=%v cause=%q current=%v", r.Revision, r.ReplicaSet, r.Images, r.ChangeCause, r.Current)
+	}
+	if len(revisions) != 3 || revisions[0].Revision != 1 || revisions[2].Revision != 3 {
+		t.Fatalf("unexpected revisions: %+v", revisions)
+	}
+	if !revisions[2].Current || revisions[1].Current || revisions[1].ChangeCause != "upgrade to 1.26" {
+		t.Errorf("unexpected current/change-cause: %+v", revisions)
+	}
+}
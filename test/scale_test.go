@@ -0,0 +1,22 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/relaxyabc/k8s-helper/tools"
+)
+
+func TestNormalizeScaleKind(t *testing.T) {
+	for in, want := range map[string]string{"deploy": "Deployment", "Deployments": "Deployment", "STS": "StatefulSet", "statefulset": "StatefulSet"} {
+		got, err := tools.NormalizeScaleKind(in)
+		if err != nil || got != want {
+			t.Errorf("%s: got %s (%v), want %s", in, got, err, want)
+		}
+	}
+	// describe 支持但不能扩缩容的类型
+	for _, kind := range []string{"DaemonSet", "Service", "Pod", "CronJob"} {
+		if _, err := tools.NormalizeScaleKind(kind); err == nil {
+			t.Errorf("%s 不支持扩缩容，应返回错误", kind)
+		}
+	}
+}
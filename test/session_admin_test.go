@@ -0,0 +1,60 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/relaxyabc/k8s-helper/common"
+	"github.com/relaxyabc/k8s-helper/mcp"
+)
+
+func TestSessionAdminHandlers(t *testing.T) {
+	s := mcp.NewMCPServer()
+	sm := mcp.NewHTTPSessionManager(mcp.NewMemorySessionStore(), time.Minute, s)
+	sm.SetPolicy(mcp.SessionPolicy{Mode: mcp.SessionPolicyLimit, MaxPerUser: 3})
+	mux := http.NewServeMux()
+	s.RegisterSessionHandlers(mux)
+	handler := mcp.SessionMiddleware(sm, mux)
+	admin, _ := sm.CreateSession("root", "admin")
+	guest, _ := sm.CreateSession("frank", "guest")
+	sm.RecordToolCall(guest.ID, "get_pods")
+
+	do := func(method, path, sid string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set(common.HeaderMcpSessionId, sid)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		t.Logf("%s %s -> %d %s", method, path, rec.Code, rec.Body.String())
+		return rec
+	}
+
+	if rec := do(http.MethodGet, "/admin/sessions", guest.ID); rec.Code != http.StatusForbidden {
+		t.Errorf("guest list: got %d", rec.Code)
+	}
+	rec := do(http.MethodGet, "/admin/sessions?user_id=frank", admin.ID)
+	var sessions []struct {
+		ID       string `json:"id"`
+		UserID   string `json:"user_id"`
+		Role     string `json:"role"`
+		LastTool string `json:"last_tool"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &sessions); err != nil {
+		t.Fatalf("decode sessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != guest.ID || sessions[0].Role != "guest" || sessions[0].LastTool != "get_pods" {
+		t.Errorf("unexpected sessions: %+v", sessions)
+	}
+
+	if rec := do(http.MethodDelete, "/admin/sessions/"+guest.ID, admin.ID); rec.Code != http.StatusNoContent {
+		t.Errorf("revoke: got %d", rec.Code)
+	}
+	if _, ok := sm.GetSession(guest.ID); ok {
+		t.Error("revoked session should not be found")
+	}
+	if rec := do(http.MethodDelete, "/admin/sessions/"+guest.ID, admin.ID); rec.Code != http.StatusNotFound {
+		t.Errorf("revoke again: got %d", rec.Code)
+	}
+}
@@ -0,0 +1,58 @@
+package test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/relaxyabc/k8s-helper/dao"
+	"github.com/relaxyabc/k8s-helper/mcp"
+)
+
+// countingStore 统计 Get 次数的会话存储
+type countingStore struct {
+	mcp.SessionStore
+	gets atomic.Int64
+}
+
+func (c *countingStore) Get(id string) (*mcp.HTTPSession, error) {
+	c.gets.Add(1)
+	return c.SessionStore.Get(id)
+}
+
+func TestContextAppendedToResults(t *testing.T) {
+	openTestDB(t)
+	if err := dao.CreateCluster(&dao.Cluster{ClusterName: "test-bj", KubeConfig: testKubeConfig}); err != nil {
+		t.Fatalf("create cluster: %v", err)
+	}
+	s := mcp.NewMCPServer()
+	store := &countingStore{SessionStore: mcp.NewMemorySessionStore()}
+	sm := mcp.NewHTTPSessionManager(store, time.Minute, s)
+	srv := httptest.NewServer(mcp.SessionMiddleware(sm, s.ServeHTTP()))
+	defer srv.Close()
+	ses, _ := sm.CreateSession("alice", "user")
+
+	// 未选择时明确说明
+	texts, isError := callTool(t, srv.URL, ses.ID, "get_clusters", nil)
+	if last := texts[len(texts)-1]; isError || !strings.Contains(last, "未选择集群和命名空间") {
+		t.Errorf("context line without selection: %q", texts)
+	}
+
+	// use_cluster 的结果附加修改后的上下文
+	texts, isError = callTool(t, srv.URL, ses.ID, "use_cluster", map[string]any{"cluster_name": "test-bj"})
+	if last := texts[len(texts)-1]; isError || !strings.Contains(last, "cluster=test-bj") {
+		t.Fatalf("use_cluster: %q", texts)
+	}
+
+	// 请求中间件续期和工具调用各读取一次会话
+	before := store.gets.Load()
+	texts, _ = callTool(t, srv.URL, ses.ID, "get_clusters", nil)
+	if last := texts[len(texts)-1]; !strings.Contains(last, "cluster=test-bj") {
+		t.Errorf("context line after use_cluster: %q", texts)
+	}
+	if gets := store.gets.Load() - before; gets != 2 {
+		t.Errorf("one tool call should read the session twice, got %d", gets)
+	}
+}
@@ -0,0 +1,168 @@
+package test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relaxyabc/k8s-helper/common"
+	"github.com/relaxyabc/k8s-helper/mcp"
+)
+
+func TestSessionStoreSharedAcrossManagers(t *testing.T) {
+	store := mcp.NewMemorySessionStore()
+	first := mcp.NewHTTPSessionManager(store, time.Minute, nil)
+	ses, _ := first.CreateSession("alice", "oncall")
+	ses.Data["cluster"] = "dev"
+	first.SaveSession(ses)
+	if again, _ := first.CreateSession("alice", "oncall"); again.ID != ses.ID {
+		t.Errorf("session of same user should be reused: %s != %s", again.ID, ses.ID)
+	}
+
+	// 模拟重启或另一个副本：新的管理器共享同一存储
+	second := mcp.NewHTTPSessionManager(store, time.Minute, nil)
+	got, ok := second.GetSession(ses.ID)
+	if !ok {
+		t.Fatalf("session %s not found by second manager", ses.ID)
+	}
+	t.Logf("%s user=%s role=%s data=%v expires=%v", got.ID, got.UserID, got.Role, got.Data, got.ExpiresAt)
+	if got.UserID != "alice" || got.Role != "oncall" || got.Data["cluster"] != "dev" {
+		t.Errorf("unexpected session: %+v", got)
+	}
+	if id, ok := second.Identity(ses.ID); !ok || id.UserID != "alice" || id.Role != "oncall" {
+		t.Errorf("identity of session: got %+v, %v", id, ok)
+	}
+
+	second.DeleteSession(ses.ID)
+	if _, ok := first.GetSession(ses.ID); ok {
+		t.Error("deleted session should not be found")
+	}
+	if _, ok := first.Identity(ses.ID); ok {
+		t.Error("identity of deleted session should not be found")
+	}
+}
+
+func TestSessionStoreExpired(t *testing.T) {
+	store := mcp.NewMemorySessionStore()
+	sm := mcp.NewHTTPSessionManager(store, time.Minute, nil)
+	ses, _ := sm.CreateSession("bob", "user")
+	ses.ExpiresAt = time.Now().Add(-time.Second)
+	sm.SaveSession(ses)
+	if _, ok := sm.GetSession(ses.ID); ok {
+		t.Error("expired session should not be found")
+	}
+	if s, _ := store.Get(ses.ID); s != nil {
+		t.Error("expired session should be deleted from store")
+	}
+	if renewed, _ := sm.CreateSession("bob", "user"); renewed.ID == ses.ID {
+		t.Error("expired session should not be reused")
+	}
+}
+
+func TestMemorySessionStoreReturnsCopies(t *testing.T) {
+	store := mcp.NewMemorySessionStore()
+	sm := mcp.NewHTTPSessionManager(store, time.Minute, nil)
+	ses, _ := sm.CreateSession("frank", "user")
+	ses.Role = "admin"
+	ses.Data["cluster"] = "dev"
+	listed, _ := sm.ListSessions("frank")
+	if len(listed) != 1 || listed[0].Role != "user" || listed[0].Data["cluster"] != nil {
+		t.Fatalf("unsaved changes should not be visible: %+v", listed)
+	}
+	listed[0].LastTool = "get_pods"
+	if got, _ := store.Get(ses.ID); got.LastTool != "" {
+		t.Errorf("changes to listed session should not modify the store: %+v", got)
+	}
+}
+
+func TestNewSessionStore(t *testing.T) {
+	for kind, wantErr := range map[string]bool{"": false, mcp.SessionStoreMemory: false, mcp.SessionStorePostgres: false, "redis": true} {
+		if _, err := mcp.NewSessionStore(kind); (err != nil) != wantErr {
+			t.Errorf("NewSessionStore(%q): err=%v", kind, err)
+		}
+	}
+}
+
+func TestSessionIdentityForHTTPHandlers(t *testing.T) {
+	s := mcp.NewMCPServer()
+	sm := mcp.NewHTTPSessionManager(mcp.NewMemorySessionStore(), time.Minute, s)
+	mux := http.NewServeMux()
+	s.RegisterApprovalHandlers(mux)
+	handler := mcp.SessionMiddleware(sm, mux)
+
+	// guest 会话无 list_approvals 权限
+	guest, _ := sm.CreateSession("carol", "guest")
+	req := httptest.NewRequest(http.MethodGet, "/admin/approvals", nil)
+	req.Header.Set(common.HeaderMcpSessionId, guest.ID)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	t.Logf("guest GET /admin/approvals -> %d %s", rec.Code, rec.Body.String())
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "guest") {
+		t.Errorf("guest: got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestSessionPolicy(t *testing.T) {
+	limit := mcp.NewHTTPSessionManager(mcp.NewMemorySessionStore(), time.Minute, nil)
+	limit.SetPolicy(mcp.SessionPolicy{Mode: mcp.SessionPolicyLimit, MaxPerUser: 2})
+	a, _ := limit.CreateSession("dave", "user")
+	b, _ := limit.CreateSession("dave", "user")
+	if a.ID == b.ID {
+		t.Error("limit policy should create separate sessions")
+	}
+	if _, err := limit.CreateSession("dave", "user"); !errors.Is(err, mcp.ErrSessionLimit) {
+		t.Errorf("third session: got %v, want ErrSessionLimit", err)
+	}
+	if _, err := limit.CreateSession("erin", "user"); err != nil {
+		t.Errorf("other user should not be limited: %v", err)
+	}
+
+	evict := mcp.NewHTTPSessionManager(mcp.NewMemorySessionStore(), time.Minute, nil)
+	evict.SetPolicy(mcp.SessionPolicy{Mode: mcp.SessionPolicyEvict, MaxPerUser: 2})
+	first, _ := evict.CreateSession("dave", "user")
+	time.Sleep(time.Millisecond)
+	evict.CreateSession("dave", "user")
+	time.Sleep(time.Millisecond)
+	evict.CreateSession("dave", "user")
+	sessions, _ := evict.ListSessions("dave")
+	t.Logf("evict policy sessions of dave: %d", len(sessions))
+	if len(sessions) != 2 {
+		t.Errorf("evict policy: got %d sessions, want 2", len(sessions))
+	}
+	if _, ok := evict.GetSession(first.ID); ok {
+		t.Error("oldest session should be evicted")
+	}
+
+	for _, p := range []mcp.SessionPolicy{{Mode: "none"}, {Mode: mcp.SessionPolicyLimit}} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("policy %+v should be invalid", p)
+		}
+	}
+}
+
+func TestSessionData(t *testing.T) {
+	sm := mcp.NewHTTPSessionManager(mcp.NewMemorySessionStore(), time.Minute, nil)
+	ses, _ := sm.CreateSession("carol", "user")
+	if err := sm.SetData(ses.ID, map[string]any{"current_cluster": "dev", "current_namespace": "web"}); err != nil {
+		t.Fatalf("set data: %v", err)
+	}
+	data := sm.Data(ses.ID)
+	data["current_cluster"] = "changed"
+	if err := sm.SetData(ses.ID, map[string]any{"current_namespace": nil}); err != nil {
+		t.Fatalf("clear data: %v", err)
+	}
+	data = sm.Data(ses.ID)
+	t.Logf("%s data=%v", ses.ID, data)
+	if data["current_cluster"] != "dev" {
+		t.Errorf("data should be a copy, got %v", data["current_cluster"])
+	}
+	if _, ok := data["current_namespace"]; ok {
+		t.Error("nil value should delete the key")
+	}
+	if err := sm.SetData("missing", map[string]any{"current_cluster": "dev"}); err == nil {
+		t.Error("set data of missing session should fail")
+	}
+}
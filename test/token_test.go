@@ -0,0 +1,127 @@
+package test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relaxyabc/k8s-helper/common"
+	"github.com/relaxyabc/k8s-helper/crypto"
+	"github.com/relaxyabc/k8s-helper/mcp"
+)
+
+func TestIssueAndParseToken(t *testing.T) {
+	keys, _ := crypto.ParseKeyRing("t1:" + testMasterKey('t'))
+	now := time.Now()
+	token, err := keys.IssueToken("alice", "admin", time.Hour, now)
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+	t.Logf("token=%s", token)
+	if !strings.HasPrefix(token, crypto.TokenPrefix+"t1.") || strings.ContainsAny(token, "+/= ") {
+		t.Fatalf("unexpected token format: %s", token)
+	}
+	claims, err := keys.ParseToken(token, now)
+	if err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+	if claims.Name != "alice" || claims.Role != "admin" || claims.ID == "" || claims.ExpiresAt-claims.IssuedAt != 3600 {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+	if _, err := keys.ParseToken(token, now.Add(2*time.Hour)); !errors.Is(err, crypto.ErrTokenExpired) {
+		t.Errorf("expired token: got %v", err)
+	}
+	tampered := token[:len(token)-2] + "AA"
+	if _, err := keys.ParseToken(tampered, now); !errors.Is(err, crypto.ErrTokenInvalid) {
+		t.Errorf("tampered token: got %v", err)
+	}
+	other, _ := crypto.ParseKeyRing("t2:" + testMasterKey('u'))
+	if _, err := other.ParseToken(token, now); !errors.Is(err, crypto.ErrTokenInvalid) {
+		t.Errorf("token of unknown key: got %v", err)
+	}
+	rotated, _ := crypto.ParseKeyRing("t2:" + testMasterKey('u') + ",t1:" + testMasterKey('t'))
+	if _, err := rotated.ParseToken(token, now); err != nil {
+		t.Errorf("token of rotated key: %v", err)
+	}
+}
+
+func TestParseUserIDAndRoleFromSID(t *testing.T) {
+	keys, _ := crypto.ParseKeyRing("t1:" + testMasterKey('t'))
+	token, _ := keys.IssueToken("alice", "user", time.Hour, time.Now())
+	legacy, _ := crypto.AESEncryptBase64(`{"name":"bob","role":"admin"}`, mcp.AESKey)
+	defer func() { mcp.TokenKeys, mcp.AllowLegacyMcpID = nil, false }()
+
+	mcp.TokenKeys = keys
+	if user, role, exp := mcp.ParseUserIDAndRoleFromSID(token); user != "alice" || role != "user" || exp.Before(time.Now().Add(59*time.Minute)) {
+		t.Errorf("token: got %s/%s, exp=%v", user, role, exp)
+	}
+	if user, _, _ := mcp.ParseUserIDAndRoleFromSID(legacy); user != "" {
+		t.Errorf("legacy mcpId should be rejected by default, got %s", user)
+	}
+	mcp.AllowLegacyMcpID = true
+	if user, role, exp := mcp.ParseUserIDAndRoleFromSID(legacy); user != "bob" || role != "admin" || !exp.IsZero() {
+		t.Errorf("legacy mcpId: got %s/%s, exp=%v", user, role, exp)
+	}
+}
+
+func TestSessionExpiresWithToken(t *testing.T) {
+	keys, _ := crypto.ParseKeyRing("t1:" + testMasterKey('t'))
+	mcp.TokenKeys = keys
+	defer func() { mcp.TokenKeys = nil }()
+	token, _ := keys.IssueToken("alice", "user", time.Second, time.Now())
+	sm := mcp.NewHTTPSessionManager(mcp.NewMemorySessionStore(), 30*time.Minute, nil)
+	handler := mcp.SessionMiddleware(sm, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/mcp?"+common.McpIDParam+"="+url.QueryEscape(token), nil))
+	sid := rec.Header().Get(common.HeaderMcpSessionId)
+	ses, ok := sm.GetSession(sid)
+	if !ok {
+		t.Fatalf("session %q not created from token", sid)
+	}
+	if ses.UserID != "alice" || ses.TokenExpiresAt.IsZero() || ses.ExpiresAt.After(ses.TokenExpiresAt) {
+		t.Fatalf("session should expire with token: expires=%v, token expires=%v", ses.ExpiresAt, ses.TokenExpiresAt)
+	}
+
+	// 令牌过期后滑动续期不再延长会话
+	time.Sleep(time.Until(ses.TokenExpiresAt) + 100*time.Millisecond)
+	if _, ok := sm.GetSession(sid); ok {
+		t.Error("session should expire with its token")
+	}
+	req := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+	req.Header.Set(common.HeaderMcpSessionId, sid)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get(common.HeaderMcpSessionId); got == "" || got == sid {
+		t.Errorf("expired session should not be reused, got %q", got)
+	}
+}
+
+func TestSharedSessionKeepsEarliestTokenExpiry(t *testing.T) {
+	sm := mcp.NewHTTPSessionManager(mcp.NewMemorySessionStore(), 30*time.Minute, nil)
+	now := time.Now()
+	first, _ := sm.CreateTokenSession("alice", "user", now.Add(10*time.Minute))
+
+	// 旧版 mcpId（无过期时间）加入不解除限制，更晚的令牌不延长，更早的令牌缩短
+	for _, c := range []struct {
+		name      string
+		expiresAt time.Time
+		want      time.Time
+	}{
+		{"legacy", time.Time{}, now.Add(10 * time.Minute)},
+		{"later", now.Add(time.Hour), now.Add(10 * time.Minute)},
+		{"earlier", now.Add(5 * time.Minute), now.Add(5 * time.Minute)},
+	} {
+		ses, err := sm.CreateTokenSession("alice", "user", c.expiresAt)
+		if err != nil || ses.ID != first.ID {
+			t.Fatalf("%s: share policy should reuse the session: %v", c.name, err)
+		}
+		if !ses.TokenExpiresAt.Equal(c.want) || ses.ExpiresAt.After(c.want) {
+			t.Errorf("%s: token expires=%v, expires=%v, want %v", c.name, ses.TokenExpiresAt, ses.ExpiresAt, c.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relaxyabc/k8s-helper/dao"
+	"github.com/relaxyabc/k8s-helper/mcp"
+)
+
+func TestToolArgumentValidation(t *testing.T) {
+	openTestDB(t)
+	if err := dao.CreateCluster(&dao.Cluster{ClusterName: "test-bj", KubeConfig: testKubeConfig}); err != nil {
+		t.Fatalf("create cluster: %v", err)
+	}
+	s := mcp.NewMCPServer()
+	// 注册仅 SSE 模式提供的日志 follow 工具，参数校验与传输方式无关
+	s.RegisterSSEPushTool(nil)
+	sm := mcp.NewHTTPSessionManager(mcp.NewMemorySessionStore(), time.Minute, s)
+	srv := httptest.NewServer(mcp.SessionMiddleware(sm, s.ServeHTTP()))
+	defer srv.Close()
+	admin, _ := sm.CreateSession("root", "admin")
+
+	target := map[string]any{"cluster_name": "test-bj", "namespace": "default", "name": "web"}
+	cases := []struct {
+		tool string
+		args map[string]any
+		want string
+	}{
+		{"rollout_status", map[string]any{"timeout_seconds": -1}, "timeout_seconds 不能为负数"},
+		{"follow_pod_logs", map[string]any{"timeout_seconds": -1}, "timeout_seconds 必须大于 0"},
+		{"follow_pod_logs", map[string]any{"timeout_seconds": 0}, "timeout_seconds 必须大于 0"},
+		{"scale_workload", map[string]any{"kind": "Deployment", "replicas": 1.5}, "replicas 必须为 0 到 2147483647 之间的整数"},
+		{"scale_workload", map[string]any{"kind": "Deployment", "replicas": -1}, "replicas 必须为 0 到 2147483647 之间的整数"},
+		{"scale_workload", map[string]any{"kind": "Deployment", "replicas": 1 << 32}, "replicas 必须为 0 到 2147483647 之间的整数"},
+	}
+	for _, c := range cases {
+		args := map[string]any{}
+		for k, v := range target {
+			args[k] = v
+		}
+		for k, v := range c.args {
+			args[k] = v
+		}
+		texts, isError := callTool(t, srv.URL, admin.ID, c.tool, args)
+		if !isError || !strings.Contains(texts[0], c.want) {
+			t.Errorf("%s %v: got %q, want error %q", c.tool, c.args, texts, c.want)
+		}
+	}
+}
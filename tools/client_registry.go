@@ -0,0 +1,152 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/relaxyabc/k8s-helper/dao"
+	"k8s.io/apimachinery/pkg/api/meta"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// clientRevalidateInterval 缓存的 client 超过该时长后，重新比对数据库中的 kubeconfig
+	clientRevalidateInterval = 30 * time.Second
+	// clientIdleTTL client 闲置超过该时长后被回收
+	clientIdleTTL = 10 * time.Minute
+)
+
+// cachedClient 按集群缓存的 k8s client
+type cachedClient struct {
+	fingerprint string // kubeconfig、TLS 设置与代理地址的摘要，用于判断配置是否变化
+	config      *rest.Config
+	httpClient  *http.Client // clientset 和 dynamic 共用，回收时关闭其空闲连接
+	clientset   *kubernetes.Clientset
+	dynamic     *dynamic.DynamicClient
+	mapper      meta.ResettableRESTMapper // 基于 discovery 的 kind/简称解析，结果缓存在内存中
+	checkedAt   time.Time
+	lastUsed    time.Time
+}
+
+// clientRegistry 集群名 -> k8s client 的缓存，避免每次调用都查库、解析 kubeconfig、新建 TLS 连接
+type clientRegistry struct {
+	mu      sync.Mutex
+	clients map[string]*cachedClient
+	janitor sync.Once
+}
+
+var registry = &clientRegistry{clients: make(map[string]*cachedClient)}
+
+// GetClusterClientset 获取指定集群的 clientset，优先复用缓存
+func GetClusterClientset(proxyAddr, clusterName string) (*kubernetes.Clientset, error) {
+	c, err := registry.get(proxyAddr, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	return c.clientset, nil
+}
+
+// InvalidateClusterClient 丢弃指定集群的缓存 client，下次调用时重建
+func InvalidateClusterClient(clusterName string) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.remove(clusterName)
+}
+
+// remove 从缓存中移除 client 并关闭其空闲连接，正在进行的请求不受影响，调用方需持有 r.mu
+func (r *clientRegistry) remove(clusterName string) {
+	if c, ok := r.clients[clusterName]; ok {
+		delete(r.clients, clusterName)
+		// 无需定制 transport 时 client-go 返回的 http.Client 使用 http.DefaultTransport
+		transport := c.httpClient.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		utilnet.CloseIdleConnectionsFor(transport)
+	}
+}
+
+func (r *clientRegistry) get(proxyAddr, clusterName string) (*cachedClient, error) {
+	r.janitor.Do(func() { go r.evictIdle() })
+	now := time.Now()
+
+	r.mu.Lock()
+	if c, ok := r.clients[clusterName]; ok && now.Sub(c.checkedAt) < clientRevalidateInterval {
+		c.lastUsed = now
+		r.mu.Unlock()
+		return c, nil
+	}
+	r.mu.Unlock()
+
+	// 查库放在锁外，避免慢查询阻塞其它集群
+	conn, err := dao.GetClusterConnection(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%t\x00%s", conn.KubeConfig, proxyAddr, conn.InsecureSkipTLSVerify, conn.CABundle)))
+	fingerprint := hex.EncodeToString(sum[:])
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.clients[clusterName]; ok && c.fingerprint == fingerprint {
+		c.checkedAt = now
+		c.lastUsed = now
+		return c, nil
+	}
+	config, err := buildRESTConfig(conn.KubeConfig, proxyAddr, conn.InsecureSkipTLSVerify, conn.CABundle)
+	if err != nil {
+		return nil, err
+	}
+	httpClient, err := rest.HTTPClientFor(config)
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfigAndClient(config, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := dynamic.NewForConfigAndClient(config, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	// 配置变化，回收旧 client 的连接
+	r.remove(clusterName)
+	c := &cachedClient{
+		fingerprint: fingerprint,
+		config:      config,
+		httpClient:  httpClient,
+		clientset:   clientset,
+		dynamic:     dynamicClient,
+		mapper:      NewResourceMapper(clientset.Discovery()),
+		checkedAt:   now,
+		lastUsed:    now,
+	}
+	r.clients[clusterName] = c
+	klog.Infof("[K8S_CLIENT] Built client for cluster %s", clusterName)
+	return c, nil
+}
+
+// evictIdle 定时回收闲置的 client
+func (r *clientRegistry) evictIdle() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		now := time.Now()
+		r.mu.Lock()
+		for name, c := range r.clients {
+			if now.Sub(c.lastUsed) > clientIdleTTL {
+				r.remove(name)
+				klog.Infof("[K8S_CLIENT] Evicted idle client for cluster %s", name)
+			}
+		}
+		r.mu.Unlock()
+	}
+}
@@ -0,0 +1,376 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// describeEventLimit describe 结果中附带的最近事件条数
+const describeEventLimit = 10
+
+// DescribeKinds describe_resource 支持的资源类型
+var DescribeKinds = []string{"Deployment", "DaemonSet", "StatefulSet", "Service", "Pod"}
+
+// describeKindAliases 资源类型别名（小写）-> 标准类型名
+var describeKindAliases = map[string]string{
+	"deployment": "Deployment", "deployments": "Deployment", "deploy": "Deployment",
+	"daemonset": "DaemonSet", "daemonsets": "DaemonSet", "ds": "DaemonSet",
+	"statefulset": "StatefulSet", "statefulsets": "StatefulSet", "sts": "StatefulSet",
+	"service": "Service", "services": "Service", "svc": "Service",
+	"pod": "Pod", "pods": "Pod", "po": "Pod",
+}
+
+// ResourceDescription kubectl describe 风格的资源摘要
+type ResourceDescription struct {
+	Kind       string             `json:"kind"`
+	Name       string             `json:"name"`
+	Namespace  string             `json:"namespace"`
+	CreatedAt  string             `json:"created_at"`
+	Labels     map[string]string  `json:"labels,omitempty"`
+	Selector   string             `json:"selector,omitempty"`
+	Replicas   *ReplicaCounts     `json:"replicas,omitempty"`
+	Spec       map[string]any     `json:"spec,omitempty"` // 各类型的关键配置
+	Containers []ContainerSummary `json:"containers,omitempty"`
+	Conditions []ConditionSummary `json:"conditions,omitempty"`
+	Events     []EventSummary     `json:"events,omitempty"`
+}
+
+// ReplicaCounts 副本数统计
+type ReplicaCounts struct {
+	Desired   int32 `json:"desired"`
+	Current   int32 `json:"current"`
+	Ready     int32 `json:"ready"`
+	Updated   int32 `json:"updated"`
+	Available int32 `json:"available"`
+}
+
+// ContainerSummary 容器配置摘要，Pod 类型额外包含运行状态
+type ContainerSummary struct {
+	Name     string            `json:"name"`
+	Image    string            `json:"image"`
+	Ports    []string          `json:"ports,omitempty"`
+	Requests map[string]string `json:"requests,omitempty"`
+	Limits   map[string]string `json:"limits,omitempty"`
+	Ready    *bool             `json:"ready,omitempty"`
+	Restarts *int32            `json:"restarts,omitempty"`
+	State    string            `json:"state,omitempty"`
+}
+
+// ConditionSummary 状态条件摘要
+type ConditionSummary struct {
+	Type           string `json:"type"`
+	Status         string `json:"status"`
+	Reason         string `json:"reason,omitempty"`
+	Message        string `json:"message,omitempty"`
+	LastTransition string `json:"last_transition,omitempty"`
+}
+
+// NormalizeDescribeKind 将资源类型或别名转换为标准类型名，不支持时返回错误
+func NormalizeDescribeKind(kind string) (string, error) {
+	if k, ok := describeKindAliases[strings.ToLower(kind)]; ok {
+		return k, nil
+	}
+	return "", fmt.Errorf("unsupported kind %s, supported: %s", kind, strings.Join(DescribeKinds, ", "))
+}
+
+// summarizeContainers 提取容器镜像、端口和资源配置
+func summarizeContainers(containers []corev1.Container) []ContainerSummary {
+	var result []ContainerSummary
+	for _, c := range containers {
+		cs := ContainerSummary{Name: c.Name, Image: c.Image}
+		for _, p := range c.Ports {
+			cs.Ports = append(cs.Ports, fmt.Sprintf("%d/%s", p.ContainerPort, p.Protocol))
+		}
+		if len(c.Resources.Requests) > 0 {
+			cs.Requests = map[string]string{}
+			for name, q := range c.Resources.Requests {
+				cs.Requests[string(name)] = q.String()
+			}
+		}
+		if len(c.Resources.Limits) > 0 {
+			cs.Limits = map[string]string{}
+			for name, q := range c.Resources.Limits {
+				cs.Limits[string(name)] = q.String()
+			}
+		}
+		result = append(result, cs)
+	}
+	return result
+}
+
+// formatTime 格式化时间，零值返回空串
+func formatTime(t metav1.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// newDescription 填充通用元数据
+func newDescription(kind string, meta metav1.ObjectMeta) *ResourceDescription {
+	return &ResourceDescription{
+		Kind:      kind,
+		Name:      meta.Name,
+		Namespace: meta.Namespace,
+		CreatedAt: formatTime(meta.CreationTimestamp),
+		Labels:    meta.Labels,
+	}
+}
+
+// DescribeDeployment 生成 Deployment 摘要
+func DescribeDeployment(d *appsv1.Deployment) *ResourceDescription {
+	desc := newDescription("Deployment", d.ObjectMeta)
+	desc.Selector = metav1.FormatLabelSelector(d.Spec.Selector)
+	desired := int32(1)
+	if d.Spec.Replicas != nil {
+		desired = *d.Spec.Replicas
+	}
+	desc.Replicas = &ReplicaCounts{
+		Desired:   desired,
+		Current:   d.Status.Replicas,
+		Ready:     d.Status.ReadyReplicas,
+		Updated:   d.Status.UpdatedReplicas,
+		Available: d.Status.AvailableReplicas,
+	}
+	desc.Spec = map[string]any{
+		"strategy":            string(d.Spec.Strategy.Type),
+		"revision":            d.Annotations["deployment.kubernetes.io/revision"],
+		"paused":              d.Spec.Paused,
+		"min_ready_seconds":   d.Spec.MinReadySeconds,
+		"observed_generation": d.Status.ObservedGeneration,
+		"generation":          d.Generation,
+	}
+	if ru := d.Spec.Strategy.RollingUpdate; ru != nil {
+		if ru.MaxSurge != nil {
+			desc.Spec["max_surge"] = ru.MaxSurge.String()
+		}
+		if ru.MaxUnavailable != nil {
+			desc.Spec["max_unavailable"] = ru.MaxUnavailable.String()
+		}
+	}
+	desc.Containers = summarizeContainers(d.Spec.Template.Spec.Containers)
+	for _, c := range d.Status.Conditions {
+		desc.Conditions = append(desc.Conditions, ConditionSummary{
+			Type: string(c.Type), Status: string(c.Status), Reason: c.Reason, Message: c.Message, LastTransition: formatTime(c.LastTransitionTime),
+		})
+	}
+	return desc
+}
+
+// DescribeDaemonSet 生成 DaemonSet 摘要
+func DescribeDaemonSet(ds *appsv1.DaemonSet) *ResourceDescription {
+	desc := newDescription("DaemonSet", ds.ObjectMeta)
+	desc.Selector = metav1.FormatLabelSelector(ds.Spec.Selector)
+	desc.Replicas = &ReplicaCounts{
+		Desired:   ds.Status.DesiredNumberScheduled,
+		Current:   ds.Status.CurrentNumberScheduled,
+		Ready:     ds.Status.NumberReady,
+		Updated:   ds.Status.UpdatedNumberScheduled,
+		Available: ds.Status.NumberAvailable,
+	}
+	desc.Spec = map[string]any{
+		"update_strategy":     string(ds.Spec.UpdateStrategy.Type),
+		"node_selector":       ds.Spec.Template.Spec.NodeSelector,
+		"misscheduled":        ds.Status.NumberMisscheduled,
+		"unavailable":         ds.Status.NumberUnavailable,
+		"observed_generation": ds.Status.ObservedGeneration,
+		"generation":          ds.Generation,
+	}
+	desc.Containers = summarizeContainers(ds.Spec.Template.Spec.Containers)
+	for _, c := range ds.Status.Conditions {
+		desc.Conditions = append(desc.Conditions, ConditionSummary{
+			Type: string(c.Type), Status: string(c.Status), Reason: c.Reason, Message: c.Message, LastTransition: formatTime(c.LastTransitionTime),
+		})
+	}
+	return desc
+}
+
+// DescribeStatefulSet 生成 StatefulSet 摘要
+func DescribeStatefulSet(sts *appsv1.StatefulSet) *ResourceDescription {
+	desc := newDescription("StatefulSet", sts.ObjectMeta)
+	desc.Selector = metav1.FormatLabelSelector(sts.Spec.Selector)
+	desired := int32(1)
+	if sts.Spec.Replicas != nil {
+		desired = *sts.Spec.Replicas
+	}
+	desc.Replicas = &ReplicaCounts{
+		Desired:   desired,
+		Current:   sts.Status.CurrentReplicas,
+		Ready:     sts.Status.ReadyReplicas,
+		Updated:   sts.Status.UpdatedReplicas,
+		Available: sts.Status.AvailableReplicas,
+	}
+	var claims []string
+	for _, pvc := range sts.Spec.VolumeClaimTemplates {
+		claims = append(claims, pvc.Name)
+	}
+	desc.Spec = map[string]any{
+		"service_name":           sts.Spec.ServiceName,
+		"update_strategy":        string(sts.Spec.UpdateStrategy.Type),
+		"pod_management_policy":  string(sts.Spec.PodManagementPolicy),
+		"volume_claim_templates": claims,
+		"current_revision":       sts.Status.CurrentRevision,
+		"update_revision":        sts.Status.UpdateRevision,
+	}
+	desc.Containers = summarizeContainers(sts.Spec.Template.Spec.Containers)
+	for _, c := range sts.Status.Conditions {
+		desc.Conditions = append(desc.Conditions, ConditionSummary{
+			Type: string(c.Type), Status: string(c.Status), Reason: c.Reason, Message: c.Message, LastTransition: formatTime(c.LastTransitionTime),
+		})
+	}
+	return desc
+}
+
+// DescribeService 生成 Service 摘要，readyEndpoints 为就绪的后端地址数
+func DescribeService(svc *corev1.Service, readyEndpoints int) *ResourceDescription {
+	desc := newDescription("Service", svc.ObjectMeta)
+	var selector []string
+	for k, v := range svc.Spec.Selector {
+		selector = append(selector, k+"="+v)
+	}
+	desc.Selector = strings.Join(selector, ",")
+	var ports []string
+	for _, p := range svc.Spec.Ports {
+		port := fmt.Sprintf("%s %d->%s/%s", p.Name, p.Port, p.TargetPort.String(), p.Protocol)
+		if p.NodePort != 0 {
+			port += fmt.Sprintf(" (nodePort %d)", p.NodePort)
+		}
+		ports = append(ports, strings.TrimSpace(port))
+	}
+	var ingress []string
+	for _, ing := range svc.Status.LoadBalancer.Ingress {
+		if ing.IP != "" {
+			ingress = append(ingress, ing.IP)
+		} else {
+			ingress = append(ingress, ing.Hostname)
+		}
+	}
+	desc.Spec = map[string]any{
+		"type":             string(svc.Spec.Type),
+		"cluster_ip":       svc.Spec.ClusterIP,
+		"external_ips":     svc.Spec.ExternalIPs,
+		"ports":            ports,
+		"session_affinity": string(svc.Spec.SessionAffinity),
+		"load_balancer":    ingress,
+		"ready_endpoints":  readyEndpoints,
+	}
+	return desc
+}
+
+// DescribePod 生成 Pod 摘要
+func DescribePod(pod *corev1.Pod) *ResourceDescription {
+	desc := newDescription("Pod", pod.ObjectMeta)
+	status := SummarizePod(pod, time.Now())
+	desc.Spec = map[string]any{
+		"status":          status.Status,
+		"ready":           status.Ready,
+		"restarts":        status.Restarts,
+		"node":            pod.Spec.NodeName,
+		"pod_ip":          pod.Status.PodIP,
+		"qos_class":       string(pod.Status.QOSClass),
+		"owner":           status.Owner,
+		"service_account": pod.Spec.ServiceAccountName,
+	}
+	desc.Containers = summarizeContainers(pod.Spec.Containers)
+	for i := range desc.Containers {
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.Name != desc.Containers[i].Name {
+				continue
+			}
+			ready, restarts := cs.Ready, cs.RestartCount
+			desc.Containers[i].Ready = &ready
+			desc.Containers[i].Restarts = &restarts
+			switch {
+			case cs.State.Running != nil:
+				desc.Containers[i].State = "Running since " + formatTime(cs.State.Running.StartedAt)
+			case cs.State.Waiting != nil:
+				desc.Containers[i].State = "Waiting: " + cs.State.Waiting.Reason
+			case cs.State.Terminated != nil:
+				desc.Containers[i].State = fmt.Sprintf("Terminated: %s (exit %d)", cs.State.Terminated.Reason, cs.State.Terminated.ExitCode)
+			}
+		}
+	}
+	for _, c := range pod.Status.Conditions {
+		desc.Conditions = append(desc.Conditions, ConditionSummary{
+			Type: string(c.Type), Status: string(c.Status), Reason: c.Reason, Message: c.Message, LastTransition: formatTime(c.LastTransitionTime),
+		})
+	}
+	return desc
+}
+
+// DescribeResource 查询资源并生成 describe 风格摘要，附带最近的相关事件
+func DescribeResource(clientset *kubernetes.Clientset, kind, namespace, name string) (*ResourceDescription, error) {
+	kind, err := NormalizeDescribeKind(kind)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	var desc *ResourceDescription
+	switch kind {
+	case "Deployment":
+		d, err := clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		desc = DescribeDeployment(d)
+	case "DaemonSet":
+		ds, err := clientset.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		desc = DescribeDaemonSet(ds)
+	case "StatefulSet":
+		sts, err := clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		desc = DescribeStatefulSet(sts)
+	case "Service":
+		svc, err := clientset.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		ready := 0
+		slices, err := clientset.DiscoveryV1().EndpointSlices(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: discoveryv1.LabelServiceName + "=" + name,
+		})
+		if err == nil {
+			for _, slice := range slices.Items {
+				for _, ep := range slice.Endpoints {
+					if ep.Conditions.Ready == nil || *ep.Conditions.Ready {
+						ready += len(ep.Addresses)
+					}
+				}
+			}
+		}
+		desc = DescribeService(svc, ready)
+	case "Pod":
+		pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		desc = DescribePod(pod)
+	}
+	// 事件查询失败不影响主体结果
+	if events, err := ListEvents(clientset, EventQuery{Namespace: namespace, Kind: kind, Name: name, Limit: describeEventLimit}); err == nil {
+		desc.Events = events
+	}
+	return desc, nil
+}
+
+// DescribeResourceTool 查询指定集群中的资源并生成 describe 风格摘要
+func DescribeResourceTool(proxy, clusterName, kind, namespace, name string) (*ResourceDescription, error) {
+	clientset, err := GetClusterClientset(proxy, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	return DescribeResource(clientset, kind, namespace, name)
+}
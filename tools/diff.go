@@ -0,0 +1,190 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// diffContextLines unified diff 中每个变更块前后保留的上下文行数
+const diffContextLines = 3
+
+// MutationPreview 变更预览，dry_run 时仅经服务端校验，不实际落库
+type MutationPreview struct {
+	DryRun bool   `json:"dry_run,omitempty"`
+	Diff   string `json:"diff"` // 变更前后对象 YAML 的 unified diff，无变化时为空
+}
+
+// updateOptions 返回 Update 调用的选项，dryRun 时使用服务端 dry-run
+func updateOptions(dryRun bool) metav1.UpdateOptions {
+	if dryRun {
+		return metav1.UpdateOptions{DryRun: []string{metav1.DryRunAll}}
+	}
+	return metav1.UpdateOptions{}
+}
+
+// newMutationPreview 生成变更预览，name 用于 diff 文件头，如 deployment/web
+func newMutationPreview(name string, before, after runtime.Object, dryRun bool) (MutationPreview, error) {
+	diff, err := ObjectDiff(name, before, after)
+	return MutationPreview{DryRun: dryRun, Diff: diff}, err
+}
+
+// ObjectDiff 比较两个对象的 YAML，忽略 managedFields，返回 unified diff
+func ObjectDiff(name string, before, after runtime.Object) (string, error) {
+	from, err := objectYAML(before)
+	if err != nil {
+		return "", err
+	}
+	to, err := objectYAML(after)
+	if err != nil {
+		return "", err
+	}
+	return UnifiedDiff("a/"+name, "b/"+name, from, to), nil
+}
+
+// objectYAML 将对象序列化为 YAML，去掉 managedFields
+func objectYAML(obj runtime.Object) (string, error) {
+	obj = obj.DeepCopyObject()
+	if accessor, err := meta.Accessor(obj); err == nil {
+		accessor.SetManagedFields(nil)
+	}
+	data, err := yaml.Marshal(obj)
+	return string(data), err
+}
+
+// diffOp 一行 diff：' ' 不变，'-' 删除，'+' 新增
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// UnifiedDiff 按行比较两段文本，返回 unified diff，无差异时返回空串
+func UnifiedDiff(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+	ops := diffLines(splitLines(from), splitLines(to))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	// 扫描变更行，把相距不超过 2*diffContextLines 的变更合并为一个 hunk
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		start := max(i-diffContextLines, 0)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j
+			} else if j-end > 2*diffContextLines {
+				break
+			}
+		}
+		end = min(end+diffContextLines+1, len(ops))
+		writeHunk(&sb, ops, start, end)
+		i = end
+	}
+	return sb.String()
+}
+
+// writeHunk 输出 ops[start:end] 为一个 hunk
+func writeHunk(sb *strings.Builder, ops []diffOp, start, end int) {
+	// 计算 hunk 在两侧文本中的起始行号（从 1 开始）
+	fromLine, toLine := 1, 1
+	for _, op := range ops[:start] {
+		if op.kind != '+' {
+			fromLine++
+		}
+		if op.kind != '-' {
+			toLine++
+		}
+	}
+	fromCount, toCount := 0, 0
+	for _, op := range ops[start:end] {
+		if op.kind != '+' {
+			fromCount++
+		}
+		if op.kind != '-' {
+			toCount++
+		}
+	}
+	// 与 GNU diff 一致，某侧行数为 0 时起始行号取前一行
+	if fromCount == 0 {
+		fromLine--
+	}
+	if toCount == 0 {
+		toLine--
+	}
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", fromLine, fromCount, toLine, toCount)
+	for _, op := range ops[start:end] {
+		sb.WriteByte(op.kind)
+		sb.WriteString(op.line)
+		sb.WriteByte('\n')
+	}
+}
+
+// splitLines 按行切分文本，忽略末尾换行
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines 基于最长公共子序列计算逐行差异，先去掉公共前后缀以缩小计算量
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+
+	x, y := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	// lcs[i][j] 为 x[i:] 与 y[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			ops = append(ops, diffOp{' ', x[i]})
+			i++
+			j++
+		case j < len(y) && (i == len(x) || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, diffOp{'+', y[j]})
+			j++
+		default:
+			ops = append(ops, diffOp{'-', x[i]})
+			i++
+		}
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
@@ -0,0 +1,164 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultResourceListLimit list_resources 默认返回的条数
+const DefaultResourceListLimit = 100
+
+// 资源输出格式
+const (
+	OutputYAML = "yaml"
+	OutputJSON = "json"
+)
+
+// ResourceQuery 通用资源查询条件
+type ResourceQuery struct {
+	Resource      string // kind、复数名或简称，可带 group/version，如 Deployment、sts、certificates.cert-manager.io
+	APIVersion    string // 可选，如 apps/v1、networking.k8s.io/v1
+	Namespace     string // 集群级资源忽略；list 时为空表示全部命名空间
+	Name          string
+	LabelSelector string
+	FieldSelector string
+	Limit         int64
+	Continue      string // 上一页返回的 continue token
+}
+
+// NewResourceMapper 基于 discovery 创建 RESTMapper，支持 kind、复数名和简称，discovery 结果缓存在内存中
+func NewResourceMapper(client discovery.DiscoveryInterface) meta.ResettableRESTMapper {
+	cached := memory.NewMemCacheClient(client)
+	return restmapper.NewShortcutExpander(restmapper.NewDeferredDiscoveryRESTMapper(cached), cached, func(msg string) {
+		klog.Warningf("[K8S_CLIENT] %s", msg)
+	}).(meta.ResettableRESTMapper)
+}
+
+// ResolveResource 将资源类型解析为 REST 映射，未命中时刷新一次 discovery 缓存后重试（新安装的 CRD）
+func ResolveResource(mapper meta.ResettableRESTMapper, resource, apiVersion string) (*meta.RESTMapping, error) {
+	mapping, err := resolveResource(mapper, resource, apiVersion)
+	if meta.IsNoMatchError(err) {
+		mapper.Reset()
+		mapping, err = resolveResource(mapper, resource, apiVersion)
+	}
+	return mapping, err
+}
+
+func resolveResource(mapper meta.RESTMapper, resource, apiVersion string) (*meta.RESTMapping, error) {
+	resource = strings.ToLower(strings.TrimSpace(resource))
+	if resource == "" {
+		return nil, fmt.Errorf("resource type is empty")
+	}
+	var gvk schema.GroupVersionKind
+	var err error
+	if apiVersion != "" {
+		gv, perr := schema.ParseGroupVersion(apiVersion)
+		if perr != nil {
+			return nil, perr
+		}
+		gvk, err = mapper.KindFor(gv.WithResource(resource))
+	} else {
+		// 与 kubectl 一致：a.b.c 先按 resource.version.group 解析，失败再按 resource.group 解析
+		full, gr := schema.ParseResourceArg(resource)
+		if full != nil {
+			gvk, err = mapper.KindFor(*full)
+		}
+		if full == nil || err != nil {
+			gvk, err = mapper.KindFor(gr.WithVersion(""))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+}
+
+// StripManagedFields 去掉 metadata.managedFields，减少输出体积
+func StripManagedFields(obj *unstructured.Unstructured) {
+	obj.SetManagedFields(nil)
+}
+
+// EncodeResource 按 yaml 或 json 格式输出资源
+func EncodeResource(content map[string]any, output string) (string, error) {
+	switch strings.ToLower(output) {
+	case "", OutputYAML:
+		data, err := yaml.Marshal(content)
+		return string(data), err
+	case OutputJSON:
+		data, err := json.MarshalIndent(content, "", "  ")
+		return string(data), err
+	default:
+		return "", fmt.Errorf("unsupported output %s, supported: %s, %s", output, OutputYAML, OutputJSON)
+	}
+}
+
+// resourceInterface 按作用域返回 dynamic client 的资源接口
+func resourceInterface(client dynamic.Interface, mapping *meta.RESTMapping, namespace string) dynamic.ResourceInterface {
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		return client.Resource(mapping.Resource).Namespace(namespace)
+	}
+	return client.Resource(mapping.Resource)
+}
+
+// ListResourcesTool 查询指定集群中任意类型的资源列表，包括 CRD
+func ListResourcesTool(proxy, clusterName string, q ResourceQuery) (*unstructured.UnstructuredList, error) {
+	c, err := registry.get(proxy, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := ResolveResource(c.mapper, q.Resource, q.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultResourceListLimit
+	}
+	list, err := resourceInterface(c.dynamic, mapping, q.Namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: q.LabelSelector,
+		FieldSelector: q.FieldSelector,
+		Limit:         limit,
+		Continue:      q.Continue,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range list.Items {
+		StripManagedFields(&list.Items[i])
+	}
+	return list, nil
+}
+
+// GetResourceTool 查询指定集群中任意类型的单个资源，包括 CRD
+func GetResourceTool(proxy, clusterName string, q ResourceQuery) (*unstructured.Unstructured, error) {
+	c, err := registry.get(proxy, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := ResolveResource(c.mapper, q.Resource, q.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace && q.Namespace == "" {
+		return nil, fmt.Errorf("%s is namespaced, namespace is required", mapping.Resource.Resource)
+	}
+	obj, err := resourceInterface(c.dynamic, mapping, q.Namespace).Get(context.Background(), q.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	StripManagedFields(obj)
+	return obj, nil
+}
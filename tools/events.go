@@ -0,0 +1,154 @@
+package tools
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultEventLimit 默认返回的事件条数
+const DefaultEventLimit = 50
+
+// EventQuery 事件查询条件，Kind/Name 为空时查询整个命名空间
+type EventQuery struct {
+	Namespace string
+	Kind      string // 关联对象类型，如 Pod、Deployment
+	Name      string // 关联对象名称
+	Type      string // Warning 或 Normal，为空时不过滤
+	Limit     int
+}
+
+// EventSummary 按关联对象和 reason 去重后的事件
+type EventSummary struct {
+	Type      string `json:"type"`
+	Reason    string `json:"reason"`
+	Object    string `json:"object"`  // 如 Pod/web-5d8f9c-abcde
+	Message   string `json:"message"` // 最近一次的事件内容
+	Count     int32  `json:"count"`
+	FirstSeen string `json:"first_seen"`
+	LastSeen  string `json:"last_seen"`
+}
+
+// eventTimes 返回事件的首次和最近发生时间
+func eventTimes(e *corev1.Event) (time.Time, time.Time) {
+	first := e.FirstTimestamp.Time
+	last := e.LastTimestamp.Time
+	if last.IsZero() {
+		last = e.EventTime.Time
+	}
+	if e.Series != nil && !e.Series.LastObservedTime.IsZero() {
+		last = e.Series.LastObservedTime.Time
+	}
+	if last.IsZero() {
+		last = e.CreationTimestamp.Time
+	}
+	if first.IsZero() {
+		first = e.EventTime.Time
+	}
+	if first.IsZero() {
+		first = last
+	}
+	return first, last
+}
+
+// eventCount 返回事件的发生次数
+func eventCount(e *corev1.Event) int32 {
+	if e.Series != nil && e.Series.Count > 0 {
+		return e.Series.Count
+	}
+	if e.Count > 0 {
+		return e.Count
+	}
+	return 1
+}
+
+// SummarizeEvents 按关联对象和 reason 去重并累计次数，按最近发生时间倒序排列
+func SummarizeEvents(events []corev1.Event, eventType string, limit int) []EventSummary {
+	type group struct {
+		summary     EventSummary
+		first, last time.Time
+	}
+	groups := make(map[string]*group)
+	var keys []string
+	for i := range events {
+		e := &events[i]
+		if eventType != "" && !strings.EqualFold(e.Type, eventType) {
+			continue
+		}
+		object := e.InvolvedObject.Kind + "/" + e.InvolvedObject.Name
+		key := object + "\x00" + e.Type + "\x00" + e.Reason
+		first, last := eventTimes(e)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{
+				summary: EventSummary{Type: e.Type, Reason: e.Reason, Object: object},
+				first:   first,
+				last:    last,
+			}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		g.summary.Count += eventCount(e)
+		if first.Before(g.first) {
+			g.first = first
+		}
+		if !last.Before(g.last) {
+			g.last = last
+			g.summary.Message = e.Message
+		}
+	}
+	result := make([]EventSummary, 0, len(keys))
+	sort.SliceStable(keys, func(i, j int) bool {
+		return groups[keys[i]].last.After(groups[keys[j]].last)
+	})
+	for _, key := range keys {
+		g := groups[key]
+		g.summary.FirstSeen = g.first.Format(time.RFC3339)
+		g.summary.LastSeen = g.last.Format(time.RFC3339)
+		result = append(result, g.summary)
+	}
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// ListEvents 查询命名空间或单个对象的事件
+func ListEvents(clientset *kubernetes.Clientset, q EventQuery) ([]EventSummary, error) {
+	selector := fields.Set{}
+	if q.Kind != "" {
+		selector["involvedObject.kind"] = q.Kind
+	}
+	if q.Name != "" {
+		selector["involvedObject.name"] = q.Name
+	}
+	if q.Type != "" {
+		selector["type"] = q.Type
+	}
+	events, err := clientset.CoreV1().Events(q.Namespace).List(context.Background(), metav1.ListOptions{
+		FieldSelector: selector.AsSelector().String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultEventLimit
+	}
+	return SummarizeEvents(events.Items, q.Type, limit), nil
+}
+
+// GetEventsTool 查询指定集群中命名空间或单个对象的事件
+func GetEventsTool(proxy, clusterName string, q EventQuery) ([]EventSummary, error) {
+	clientset, err := GetClusterClientset(proxy, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	return ListEvents(clientset, q)
+}
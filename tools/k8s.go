@@ -1,21 +1,37 @@
 package tools
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/relaxyabc/k8s-helper/dao"
 	"golang.org/x/net/proxy"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
 // GetK8sClient 获取 k8s clientset，支持可选 socks5 代理和跳过 TLS 校验
 func GetK8sClient(kubeconfigData string, proxyAddr string, insecure bool) (*kubernetes.Clientset, error) {
+	config, err := buildRESTConfig(kubeconfigData, proxyAddr, insecure, "")
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// buildRESTConfig 根据 kubeconfig 构建 rest.Config，支持可选 socks5 代理
+// insecure 为 true 时跳过 TLS 校验，否则强制校验服务端证书，caBundle 非空时追加为信任的 CA
+func buildRESTConfig(kubeconfigData string, proxyAddr string, insecure bool, caBundle string) (*rest.Config, error) {
 	config, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeconfigData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to build config from kubeconfig: %w", err)
@@ -25,6 +41,20 @@ func GetK8sClient(kubeconfigData string, proxyAddr string, insecure bool) (*kube
 		config.TLSClientConfig.Insecure = true
 		config.TLSClientConfig.CAFile = ""
 		config.TLSClientConfig.CAData = nil
+	} else {
+		// 是否跳过校验以集群记录为准，忽略 kubeconfig 中的 insecure-skip-tls-verify
+		config.Insecure = false
+		if caBundle != "" {
+			if config.CAFile != "" {
+				caData, err := os.ReadFile(config.CAFile)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read CA file: %w", err)
+				}
+				config.CAData = caData
+				config.CAFile = ""
+			}
+			config.CAData = append(append(config.CAData, '\n'), caBundle...)
+		}
 	}
 	if proxyAddr != "" {
 		dialer, err := proxy.SOCKS5("tcp", proxyAddr, nil, proxy.Direct)
@@ -45,7 +75,7 @@ func GetK8sClient(kubeconfigData string, proxyAddr string, insecure bool) (*kube
 			return clonedTransport
 		}
 	}
-	return kubernetes.NewForConfig(config)
+	return config, nil
 }
 
 // ListNamespaces 获取 namespace 列表
@@ -113,95 +143,83 @@ func ListConfigMaps(clientset *kubernetes.Clientset, namespace string) ([]string
 	return result, nil
 }
 
-// RolloutRestartDeployment 滚动重启 Deployment
-func RolloutRestartDeployment(clientset *kubernetes.Clientset, namespace, name string) error {
+// RolloutRestartDeployment 滚动重启 Deployment，dryRun 时仅做服务端 dry-run，返回变更前后的 diff
+func RolloutRestartDeployment(clientset *kubernetes.Clientset, namespace, name string, dryRun bool) (MutationPreview, error) {
 	deploymentsClient := clientset.AppsV1().Deployments(namespace)
 	deployment, err := deploymentsClient.Get(context.Background(), name, metav1.GetOptions{})
 	if err != nil {
-		return err
+		return MutationPreview{}, err
 	}
+	before := deployment.DeepCopy()
 	if deployment.Spec.Template.Annotations == nil {
 		deployment.Spec.Template.Annotations = map[string]string{}
 	}
 	deployment.Spec.Template.Annotations["kubectl.kubernetes.io/restartedAt"] = metav1.Now().Format("2006-01-02T15:04:05Z07:00")
-	_, err = deploymentsClient.Update(context.Background(), deployment, metav1.UpdateOptions{})
-	return err
+	after, err := deploymentsClient.Update(context.Background(), deployment, updateOptions(dryRun))
+	if err != nil {
+		return MutationPreview{}, err
+	}
+	return newMutationPreview("deployment/"+name, before, after, dryRun)
 }
 
-// RolloutRestartDaemonSet 滚动重启 DaemonSet
-func RolloutRestartDaemonSet(clientset *kubernetes.Clientset, namespace, name string) error {
+// RolloutRestartDaemonSet 滚动重启 DaemonSet，dryRun 时仅做服务端 dry-run，返回变更前后的 diff
+func RolloutRestartDaemonSet(clientset *kubernetes.Clientset, namespace, name string, dryRun bool) (MutationPreview, error) {
 	daemonsetsClient := clientset.AppsV1().DaemonSets(namespace)
 	daemonset, err := daemonsetsClient.Get(context.Background(), name, metav1.GetOptions{})
 	if err != nil {
-		return err
+		return MutationPreview{}, err
 	}
+	before := daemonset.DeepCopy()
 	if daemonset.Spec.Template.Annotations == nil {
 		daemonset.Spec.Template.Annotations = map[string]string{}
 	}
 	daemonset.Spec.Template.Annotations["kubectl.kubernetes.io/restartedAt"] = metav1.Now().Format("2006-01-02T15:04:05Z07:00")
-	_, err = daemonsetsClient.Update(context.Background(), daemonset, metav1.UpdateOptions{})
-	return err
+	after, err := daemonsetsClient.Update(context.Background(), daemonset, updateOptions(dryRun))
+	if err != nil {
+		return MutationPreview{}, err
+	}
+	return newMutationPreview("daemonset/"+name, before, after, dryRun)
 }
 
 // RolloutRestartDeploymentTool 滚动重启 Deployment
-func RolloutRestartDeploymentTool(proxy string, clusterName, namespace, name string) error {
-	kubeconfig, err := dao.GetKubeConfig(clusterName)
+func RolloutRestartDeploymentTool(proxy string, clusterName, namespace, name string, dryRun bool) (MutationPreview, error) {
+	clientset, err := GetClusterClientset(proxy, clusterName)
 	if err != nil {
-		return err
+		return MutationPreview{}, err
 	}
-	clientset, err := GetK8sClient(kubeconfig, proxy, true)
-	if err != nil {
-		return err
-	}
-	return RolloutRestartDeployment(clientset, namespace, name)
+	return RolloutRestartDeployment(clientset, namespace, name, dryRun)
 }
 
 // RolloutRestartDaemonSetTool 滚动重启 DaemonSet
-func RolloutRestartDaemonSetTool(proxy string, clusterName, namespace, name string) error {
-	kubeconfig, err := dao.GetKubeConfig(clusterName)
+func RolloutRestartDaemonSetTool(proxy string, clusterName, namespace, name string, dryRun bool) (MutationPreview, error) {
+	clientset, err := GetClusterClientset(proxy, clusterName)
 	if err != nil {
-		return err
+		return MutationPreview{}, err
 	}
-	clientset, err := GetK8sClient(kubeconfig, proxy, true)
-	if err != nil {
-		return err
-	}
-	return RolloutRestartDaemonSet(clientset, namespace, name)
+	return RolloutRestartDaemonSet(clientset, namespace, name, dryRun)
 }
 
 // GetNamespacesTool 查询指定集群的 namespace 列表
 func GetNamespacesTool(proxy, clusterName string) ([]string, error) {
-	kubeconfig, err := dao.GetKubeConfig(clusterName)
-	if err != nil {
-		return nil, err
-	}
-	clientset, err := GetK8sClient(kubeconfig, proxy, true)
+	clientset, err := GetClusterClientset(proxy, clusterName)
 	if err != nil {
 		return nil, err
 	}
 	return ListNamespaces(clientset)
 }
 
-// GetPodsTool 获取指定集群和命名空间下的 Pod 名称列表
-func GetPodsTool(proxy, clusterName, namespace string) ([]string, error) {
-	kubeconfig, err := dao.GetKubeConfig(clusterName)
+// GetPodsTool 获取指定集群和命名空间下的 Pod 状态摘要，selector 为空时不过滤
+func GetPodsTool(proxy, clusterName, namespace, labelSelector, fieldSelector string) ([]PodStatus, error) {
+	clientset, err := GetClusterClientset(proxy, clusterName)
 	if err != nil {
 		return nil, err
 	}
-	clientset, err := GetK8sClient(kubeconfig, proxy, true)
-	if err != nil {
-		return nil, err
-	}
-	return ListPods(clientset, namespace)
+	return ListPodStatuses(clientset, namespace, labelSelector, fieldSelector)
 }
 
 // GetDeploymentsTool 获取指定集群和命名空间下的 Deployment 名称列表
 func GetDeploymentsTool(proxy, clusterName, namespace string) ([]string, error) {
-	kubeconfig, err := dao.GetKubeConfig(clusterName)
-	if err != nil {
-		return nil, err
-	}
-	clientset, err := GetK8sClient(kubeconfig, proxy, true)
+	clientset, err := GetClusterClientset(proxy, clusterName)
 	if err != nil {
 		return nil, err
 	}
@@ -210,11 +228,7 @@ func GetDeploymentsTool(proxy, clusterName, namespace string) ([]string, error)
 
 // GetDaemonSetsTool 获取指定集群和命名空间下的 DaemonSet 名称列表
 func GetDaemonSetsTool(proxy, clusterName, namespace string) ([]string, error) {
-	kubeconfig, err := dao.GetKubeConfig(clusterName)
-	if err != nil {
-		return nil, err
-	}
-	clientset, err := GetK8sClient(kubeconfig, proxy, true)
+	clientset, err := GetClusterClientset(proxy, clusterName)
 	if err != nil {
 		return nil, err
 	}
@@ -223,11 +237,7 @@ func GetDaemonSetsTool(proxy, clusterName, namespace string) ([]string, error) {
 
 // GetConfigMapsTool 获取指定集群和命名空间下的 ConfigMap 名称列表
 func GetConfigMapsTool(proxy, clusterName, namespace string) ([]string, error) {
-	kubeconfig, err := dao.GetKubeConfig(clusterName)
-	if err != nil {
-		return nil, err
-	}
-	clientset, err := GetK8sClient(kubeconfig, proxy, true)
+	clientset, err := GetClusterClientset(proxy, clusterName)
 	if err != nil {
 		return nil, err
 	}
@@ -261,34 +271,162 @@ func HTTPRequestTool(method, url, body string) (string, error) {
 
 // GetK8sVersionTool 获取指定集群的 k8s 版本
 func GetK8sVersionTool(proxy, clusterName string) (string, error) {
-	kubeconfig, err := dao.GetKubeConfig(clusterName)
+	clientset, err := GetClusterClientset(proxy, clusterName)
 	if err != nil {
 		return "", err
 	}
-	clientset, err := GetK8sClient(kubeconfig, proxy, true)
+	versionInfo, err := clientset.Discovery().ServerVersion()
 	if err != nil {
 		return "", err
 	}
-	versionInfo, err := clientset.Discovery().ServerVersion()
+	return versionInfo.String(), nil
+}
+
+// clusterCheckTimeout 注册集群前连通性检查的超时时间
+const clusterCheckTimeout = 10 * time.Second
+
+// CheckClusterConnectivity 使用给定的 kubeconfig 和 TLS 设置连接集群，成功时返回集群版本
+func CheckClusterConnectivity(proxy string, conn dao.ClusterConnection) (string, error) {
+	config, err := buildRESTConfig(conn.KubeConfig, proxy, conn.InsecureSkipTLSVerify, conn.CABundle)
 	if err != nil {
 		return "", err
 	}
+	config.Timeout = clusterCheckTimeout
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return "", err
+	}
+	versionInfo, err := clientset.Discovery().ServerVersion()
+	if err != nil {
+		return "", fmt.Errorf("connectivity check failed: %w", err)
+	}
 	return versionInfo.String(), nil
 }
 
 // GetConfigMapDetailTool 获取指定集群、命名空间、ConfigMap 名称的详细内容
 func GetConfigMapDetailTool(proxy, clusterName, namespace, name string) (map[string]string, error) {
-	kubeconfig, err := dao.GetKubeConfig(clusterName)
+	clientset, err := GetClusterClientset(proxy, clusterName)
 	if err != nil {
 		return nil, err
 	}
-	clientset, err := GetK8sClient(kubeconfig, proxy, true)
+	cm, err := clientset.CoreV1().ConfigMaps(namespace).Get(context.Background(), name, metav1.GetOptions{})
 	if err != nil {
 		return nil, err
 	}
-	cm, err := clientset.CoreV1().ConfigMaps(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	return cm.Data, nil
+}
+
+const (
+	// DefaultPodLogTailLines 未指定 tailLines 和 sinceSeconds 时默认返回的日志行数
+	DefaultPodLogTailLines = 200
+	// DefaultPodLogMaxBytes 默认返回的日志字节上限
+	DefaultPodLogMaxBytes = 64 * 1024
+	// MaxPodLogBytes 日志字节上限的最大值，避免日志淹没模型上下文
+	MaxPodLogBytes = 256 * 1024
+	// podLogTimeout 读取日志的超时时间
+	podLogTimeout = 30 * time.Second
+)
+
+// PodLogOptions 读取 Pod 日志的选项
+type PodLogOptions struct {
+	Container    string // 容器名，多容器 Pod 必填
+	TailLines    int64  // 返回最后多少行，0 表示不限制
+	SinceSeconds int64  // 返回最近多少秒的日志，0 表示不限制
+	Previous     bool   // 读取上一个（已崩溃）容器实例的日志
+	Timestamps   bool   // 每行日志带时间戳
+	MaxBytes     int    // 返回的字节上限，超出时只保留最后的部分
+}
+
+// PodLogs Pod 日志读取结果
+type PodLogs struct {
+	Logs      string `json:"logs"`
+	Truncated bool   `json:"truncated"` // 是否因超出字节上限被截断
+}
+
+// GetPodLogs 读取 Pod 日志，超出字节上限时保留最新的部分
+func GetPodLogs(clientset *kubernetes.Clientset, namespace, name string, opts PodLogOptions) (*PodLogs, error) {
+	maxBytes := opts.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultPodLogMaxBytes
+	}
+	if maxBytes > MaxPodLogBytes {
+		maxBytes = MaxPodLogBytes
+	}
+	logOpts := &corev1.PodLogOptions{
+		Container:  opts.Container,
+		Previous:   opts.Previous,
+		Timestamps: opts.Timestamps,
+	}
+	if opts.TailLines <= 0 && opts.SinceSeconds <= 0 {
+		opts.TailLines = DefaultPodLogTailLines
+	}
+	if opts.TailLines > 0 {
+		logOpts.TailLines = &opts.TailLines
+	}
+	if opts.SinceSeconds > 0 {
+		logOpts.SinceSeconds = &opts.SinceSeconds
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), podLogTimeout)
+	defer cancel()
+	stream, err := clientset.CoreV1().Pods(namespace).GetLogs(name, logOpts).Stream(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return cm.Data, nil
+	defer stream.Close()
+
+	// 只保留最后 maxBytes 字节
+	var buf []byte
+	truncated := false
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := stream.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if len(buf) > maxBytes {
+			buf = buf[len(buf)-maxBytes:]
+			truncated = true
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if truncated {
+		// 从下一行开始，避免返回半行日志
+		if i := bytes.IndexByte(buf, '\n'); i >= 0 {
+			buf = buf[i+1:]
+		}
+	}
+	return &PodLogs{Logs: string(buf), Truncated: truncated}, nil
+}
+
+// GetPodLogsTool 读取指定集群、命名空间下 Pod 的日志
+func GetPodLogsTool(proxy, clusterName, namespace, name string, opts PodLogOptions) (*PodLogs, error) {
+	clientset, err := GetClusterClientset(proxy, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	return GetPodLogs(clientset, namespace, name, opts)
+}
+
+// FollowPodLogsTool 以 follow 方式打开 Pod 日志流，ctx 取消时日志流随之关闭
+func FollowPodLogsTool(ctx context.Context, proxy, clusterName, namespace, name string, opts PodLogOptions) (io.ReadCloser, error) {
+	clientset, err := GetClusterClientset(proxy, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	logOpts := &corev1.PodLogOptions{
+		Container:  opts.Container,
+		Follow:     true,
+		Timestamps: opts.Timestamps,
+	}
+	if opts.TailLines > 0 {
+		logOpts.TailLines = &opts.TailLines
+	}
+	if opts.SinceSeconds > 0 {
+		logOpts.SinceSeconds = &opts.SinceSeconds
+	}
+	return clientset.CoreV1().Pods(namespace).GetLogs(name, logOpts).Stream(ctx)
 }
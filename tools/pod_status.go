@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PodStatus Pod 状态摘要，字段含义与 kubectl get pods -o wide 一致
+type PodStatus struct {
+	Name                  string `json:"name"`
+	Phase                 string `json:"phase"`
+	Status                string `json:"status"` // kubectl 的 STATUS 列，如 Running、CrashLoopBackOff
+	Ready                 string `json:"ready"`  // 就绪容器数/容器总数，如 1/2
+	Restarts              int32  `json:"restarts"`
+	LastTerminationReason string `json:"last_termination_reason,omitempty"`
+	Node                  string `json:"node,omitempty"`
+	PodIP                 string `json:"pod_ip,omitempty"`
+	Age                   string `json:"age"`
+	Owner                 string `json:"owner,omitempty"` // 如 ReplicaSet/nginx-5d8f9c
+}
+
+// SummarizePod 计算单个 Pod 的状态摘要，now 用于计算 Age
+func SummarizePod(pod *corev1.Pod, now time.Time) PodStatus {
+	ps := PodStatus{
+		Name:   pod.Name,
+		Phase:  string(pod.Status.Phase),
+		Status: string(pod.Status.Phase),
+		Node:   pod.Spec.NodeName,
+		PodIP:  pod.Status.PodIP,
+		Age:    "<unknown>",
+	}
+	if !pod.CreationTimestamp.IsZero() {
+		ps.Age = duration.HumanDuration(now.Sub(pod.CreationTimestamp.Time))
+	}
+	if pod.Status.Reason != "" {
+		ps.Status = pod.Status.Reason
+	}
+	for _, ref := range pod.OwnerReferences {
+		if ref.Controller != nil && *ref.Controller {
+			ps.Owner = ref.Kind + "/" + ref.Name
+			break
+		}
+	}
+
+	// init 容器未完成时，状态取 init 容器的状态
+	initializing := false
+	for i, cs := range pod.Status.InitContainerStatuses {
+		ps.Restarts += cs.RestartCount
+		switch {
+		case cs.State.Terminated != nil && cs.State.Terminated.ExitCode == 0:
+			continue
+		case cs.State.Terminated != nil:
+			ps.Status = "Init:" + cs.State.Terminated.Reason
+		case cs.State.Waiting != nil && cs.State.Waiting.Reason != "" && cs.State.Waiting.Reason != "PodInitializing":
+			ps.Status = "Init:" + cs.State.Waiting.Reason
+		default:
+			ps.Status = fmt.Sprintf("Init:%d/%d", i, len(pod.Spec.InitContainers))
+		}
+		initializing = true
+		break
+	}
+
+	ready := 0
+	for _, cs := range pod.Status.ContainerStatuses {
+		ps.Restarts += cs.RestartCount
+		if cs.Ready {
+			ready++
+		}
+		if cs.LastTerminationState.Terminated != nil && cs.LastTerminationState.Terminated.Reason != "" {
+			ps.LastTerminationReason = cs.LastTerminationState.Terminated.Reason
+		}
+		if initializing {
+			continue
+		}
+		switch {
+		case cs.State.Waiting != nil && cs.State.Waiting.Reason != "":
+			ps.Status = cs.State.Waiting.Reason
+		case cs.State.Terminated != nil && cs.State.Terminated.Reason != "":
+			ps.Status = cs.State.Terminated.Reason
+		}
+	}
+	ps.Ready = fmt.Sprintf("%d/%d", ready, len(pod.Spec.Containers))
+	if pod.DeletionTimestamp != nil {
+		ps.Status = "Terminating"
+	}
+	return ps
+}
+
+// ListPodStatuses 获取指定命名空间下的 Pod 状态摘要，支持 label/field selector 过滤
+func ListPodStatuses(clientset *kubernetes.Clientset, namespace, labelSelector, fieldSelector string) ([]PodStatus, error) {
+	pods, err := clientset.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	result := []PodStatus{}
+	for i := range pods.Items {
+		result = append(result, SummarizePod(&pods.Items[i], now))
+	}
+	return result, nil
+}
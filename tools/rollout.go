@@ -0,0 +1,350 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DefaultRolloutWaitSeconds rollout_status 默认等待时长
+	DefaultRolloutWaitSeconds = 60
+	// MaxRolloutWaitSeconds rollout_status 最大等待时长
+	MaxRolloutWaitSeconds = 300
+	// rolloutPollInterval rollout_status 轮询间隔
+	rolloutPollInterval = 2 * time.Second
+
+	revisionAnnotation    = "deployment.kubernetes.io/revision"
+	changeCauseAnnotation = "kubernetes.io/change-cause"
+)
+
+// rollbackSkipAnnotations 回滚时不从 ReplicaSet 复制到 Deployment 的注解，与 kubectl 一致
+var rollbackSkipAnnotations = map[string]bool{
+	corev1.LastAppliedConfigAnnotation:          true,
+	revisionAnnotation:                          true,
+	"deployment.kubernetes.io/revision-history": true,
+	"deployment.kubernetes.io/desired-replicas": true,
+	"deployment.kubernetes.io/max-replicas":     true,
+	appsv1.DeprecatedRollbackTo:                 true,
+}
+
+// ReplicaSetSummary Deployment 下某个版本的 ReplicaSet 摘要
+type ReplicaSetSummary struct {
+	Name      string   `json:"name"`
+	Revision  int64    `json:"revision"`
+	Desired   int32    `json:"desired"`
+	Current   int32    `json:"current"`
+	Ready     int32    `json:"ready"`
+	Available int32    `json:"available"`
+	Images    []string `json:"images"`
+}
+
+// RolloutStatus rollout 进度
+type RolloutStatus struct {
+	Name        string              `json:"name"`
+	Namespace   string              `json:"namespace"`
+	Revision    string              `json:"revision"`
+	Done        bool                `json:"done"`
+	Message     string              `json:"message"`
+	Waited      string              `json:"waited"`
+	Replicas    ReplicaCounts       `json:"replicas"`
+	Conditions  []ConditionSummary  `json:"conditions,omitempty"`
+	ReplicaSets []ReplicaSetSummary `json:"replica_sets,omitempty"` // 仍有副本的 ReplicaSet，新版本在前
+}
+
+// RolloutRevision rollout_history 中的一个版本
+type RolloutRevision struct {
+	Revision    int64    `json:"revision"`
+	ReplicaSet  string   `json:"replica_set"`
+	ChangeCause string   `json:"change_cause,omitempty"`
+	Images      []string `json:"images"`
+	CreatedAt   string   `json:"created_at"`
+	Current     bool     `json:"current"`
+}
+
+// RollbackResult rollout_undo 结果
+type RollbackResult struct {
+	Name         string   `json:"name"`
+	Namespace    string   `json:"namespace"`
+	FromRevision int64    `json:"from_revision"`
+	ToRevision   int64    `json:"to_revision"`
+	Images       []string `json:"images"`
+	Skipped      bool     `json:"skipped,omitempty"` // 目标版本与当前模板相同，未做修改
+	MutationPreview
+}
+
+// DeploymentRolloutStatus 按 kubectl rollout status 的规则判断 rollout 是否完成，返回进度描述
+func DeploymentRolloutStatus(d *appsv1.Deployment) (string, bool, error) {
+	if d.Generation > d.Status.ObservedGeneration {
+		return "Waiting for deployment spec update to be observed...", false, nil
+	}
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+			return "", false, fmt.Errorf("deployment %q exceeded its progress deadline", d.Name)
+		}
+	}
+	desired := int32(1)
+	if d.Spec.Replicas != nil {
+		desired = *d.Spec.Replicas
+	}
+	switch {
+	case d.Status.UpdatedReplicas < desired:
+		return fmt.Sprintf("Waiting for deployment %q rollout to finish: %d out of %d new replicas have been updated...", d.Name, d.Status.UpdatedReplicas, desired), false, nil
+	case d.Status.Replicas > d.Status.UpdatedReplicas:
+		return fmt.Sprintf("Waiting for deployment %q rollout to finish: %d old replicas are pending termination...", d.Name, d.Status.Replicas-d.Status.UpdatedReplicas), false, nil
+	case d.Status.AvailableReplicas < d.Status.UpdatedReplicas:
+		return fmt.Sprintf("Waiting for deployment %q rollout to finish: %d of %d updated replicas are available...", d.Name, d.Status.AvailableReplicas, d.Status.UpdatedReplicas), false, nil
+	}
+	return fmt.Sprintf("deployment %q successfully rolled out", d.Name), true, nil
+}
+
+// replicaSetRevision 读取 ReplicaSet 的版本号
+func replicaSetRevision(rs *appsv1.ReplicaSet) int64 {
+	v, _ := strconv.ParseInt(rs.Annotations[revisionAnnotation], 10, 64)
+	return v
+}
+
+// templateImages 返回 Pod 模板中的容器镜像
+func templateImages(spec corev1.PodSpec) []string {
+	images := []string{}
+	for _, c := range spec.Containers {
+		images = append(images, c.Image)
+	}
+	return images
+}
+
+// ownedReplicaSets 筛选出属于 Deployment 的 ReplicaSet，按版本号倒序
+func ownedReplicaSets(d *appsv1.Deployment, replicaSets []appsv1.ReplicaSet) []*appsv1.ReplicaSet {
+	var owned []*appsv1.ReplicaSet
+	for i := range replicaSets {
+		rs := &replicaSets[i]
+		if ref := metav1.GetControllerOf(rs); ref != nil && ref.UID == d.UID {
+			owned = append(owned, rs)
+		}
+	}
+	sort.SliceStable(owned, func(i, j int) bool {
+		return replicaSetRevision(owned[i]) > replicaSetRevision(owned[j])
+	})
+	return owned
+}
+
+// DeploymentRevisions 根据 Deployment 的 ReplicaSet 生成版本历史，按版本号升序
+func DeploymentRevisions(d *appsv1.Deployment, replicaSets []appsv1.ReplicaSet) []RolloutRevision {
+	current := d.Annotations[revisionAnnotation]
+	owned := ownedReplicaSets(d, replicaSets)
+	revisions := make([]RolloutRevision, 0, len(owned))
+	for i := len(owned) - 1; i >= 0; i-- {
+		rs := owned[i]
+		revisions = append(revisions, RolloutRevision{
+			Revision:    replicaSetRevision(rs),
+			ReplicaSet:  rs.Name,
+			ChangeCause: rs.Annotations[changeCauseAnnotation],
+			Images:      templateImages(rs.Spec.Template.Spec),
+			CreatedAt:   formatTime(rs.CreationTimestamp),
+			Current:     rs.Annotations[revisionAnnotation] == current,
+		})
+	}
+	return revisions
+}
+
+// listDeploymentReplicaSets 获取 Deployment 及其 selector 下的 ReplicaSet
+func listDeploymentReplicaSets(ctx context.Context, clientset *kubernetes.Clientset, namespace, name string) (*appsv1.Deployment, []appsv1.ReplicaSet, error) {
+	d, err := clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	selector, err := metav1.LabelSelectorAsSelector(d.Spec.Selector)
+	if err != nil {
+		return nil, nil, err
+	}
+	rsList, err := clientset.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, nil, err
+	}
+	return d, rsList.Items, nil
+}
+
+// GetRolloutStatus 等待 Deployment rollout 完成或超时，返回最后一次观察到的进度；timeout 为 0 时只查询一次，不等待
+func GetRolloutStatus(clientset *kubernetes.Clientset, namespace, name string, timeout time.Duration) (*RolloutStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	ticker := time.NewTicker(rolloutPollInterval)
+	defer ticker.Stop()
+	for {
+		// 单次查询使用独立超时，避免等待超时导致最后一次查询失败
+		queryCtx, queryCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		d, replicaSets, err := listDeploymentReplicaSets(queryCtx, clientset, namespace, name)
+		queryCancel()
+		if err != nil {
+			return nil, err
+		}
+		message, done, err := DeploymentRolloutStatus(d)
+		status := newRolloutStatus(d, replicaSets)
+		status.Done = done
+		status.Message = message
+		if err != nil {
+			status.Message = err.Error()
+		}
+		status.Waited = time.Since(start).Round(time.Second).String()
+		if done || err != nil || timeout <= 0 {
+			return status, nil
+		}
+		select {
+		case <-ctx.Done():
+			status.Message = "timed out waiting for rollout: " + status.Message
+			return status, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// newRolloutStatus 汇总副本数、状态条件和仍有副本的 ReplicaSet
+func newRolloutStatus(d *appsv1.Deployment, replicaSets []appsv1.ReplicaSet) *RolloutStatus {
+	desc := DescribeDeployment(d)
+	status := &RolloutStatus{
+		Name:       d.Name,
+		Namespace:  d.Namespace,
+		Revision:   d.Annotations[revisionAnnotation],
+		Replicas:   *desc.Replicas,
+		Conditions: desc.Conditions,
+	}
+	for _, rs := range ownedReplicaSets(d, replicaSets) {
+		if rs.Status.Replicas == 0 && (rs.Spec.Replicas == nil || *rs.Spec.Replicas == 0) {
+			continue
+		}
+		var desired int32
+		if rs.Spec.Replicas != nil {
+			desired = *rs.Spec.Replicas
+		}
+		status.ReplicaSets = append(status.ReplicaSets, ReplicaSetSummary{
+			Name:      rs.Name,
+			Revision:  replicaSetRevision(rs),
+			Desired:   desired,
+			Current:   rs.Status.Replicas,
+			Ready:     rs.Status.ReadyReplicas,
+			Available: rs.Status.AvailableReplicas,
+			Images:    templateImages(rs.Spec.Template.Spec),
+		})
+	}
+	return status
+}
+
+// GetRolloutHistory 查询 Deployment 的版本历史
+func GetRolloutHistory(clientset *kubernetes.Clientset, namespace, name string) ([]RolloutRevision, error) {
+	d, replicaSets, err := listDeploymentReplicaSets(context.Background(), clientset, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return DeploymentRevisions(d, replicaSets), nil
+}
+
+// RollbackDeployment 将 Deployment 回滚到指定版本，toRevision 为 0 时回滚到上一个版本
+// 与 kubectl rollout undo 一致：用目标 ReplicaSet 的 Pod 模板（去掉 pod-template-hash）替换当前模板
+// dryRun 时仅做服务端 dry-run，返回变更前后的 diff
+func RollbackDeployment(clientset *kubernetes.Clientset, namespace, name string, toRevision int64, dryRun bool) (*RollbackResult, error) {
+	ctx := context.Background()
+	d, replicaSets, err := listDeploymentReplicaSets(ctx, clientset, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if d.Spec.Paused {
+		return nil, fmt.Errorf("cannot rollback a paused deployment; resume it first")
+	}
+	target, current, err := findRollbackTarget(d, replicaSets, toRevision)
+	if err != nil {
+		return nil, err
+	}
+	result := &RollbackResult{
+		Name:         d.Name,
+		Namespace:    d.Namespace,
+		FromRevision: current,
+		ToRevision:   replicaSetRevision(target),
+		Images:       templateImages(target.Spec.Template.Spec),
+	}
+	result.DryRun = dryRun
+	template := target.Spec.Template.DeepCopy()
+	delete(template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+	if equalIgnoreHash(&d.Spec.Template, template) {
+		result.Skipped = true
+		return result, nil
+	}
+	before := d.DeepCopy()
+	d.Spec.Template = *template
+	if d.Annotations == nil {
+		d.Annotations = map[string]string{}
+	}
+	delete(d.Annotations, changeCauseAnnotation)
+	for k, v := range target.Annotations {
+		if !rollbackSkipAnnotations[k] {
+			d.Annotations[k] = v
+		}
+	}
+	after, err := clientset.AppsV1().Deployments(namespace).Update(ctx, d, updateOptions(dryRun))
+	if err != nil {
+		return nil, err
+	}
+	result.MutationPreview, err = newMutationPreview("deployment/"+name, before, after, dryRun)
+	return result, err
+}
+
+// findRollbackTarget 查找回滚目标 ReplicaSet，同时返回当前版本号
+func findRollbackTarget(d *appsv1.Deployment, replicaSets []appsv1.ReplicaSet, toRevision int64) (*appsv1.ReplicaSet, int64, error) {
+	current, _ := strconv.ParseInt(d.Annotations[revisionAnnotation], 10, 64)
+	for _, rs := range ownedReplicaSets(d, replicaSets) {
+		revision := replicaSetRevision(rs)
+		if toRevision == 0 && revision < current {
+			return rs, current, nil
+		}
+		if toRevision != 0 && revision == toRevision {
+			return rs, current, nil
+		}
+	}
+	if toRevision == 0 {
+		return nil, current, fmt.Errorf("no rollout history found for deployment %q", d.Name)
+	}
+	return nil, current, fmt.Errorf("unable to find revision %d of deployment %q", toRevision, d.Name)
+}
+
+// equalIgnoreHash 比较两个 Pod 模板，忽略 pod-template-hash 标签
+func equalIgnoreHash(a, b *corev1.PodTemplateSpec) bool {
+	a, b = a.DeepCopy(), b.DeepCopy()
+	delete(a.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+	delete(b.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+	return equality.Semantic.DeepEqual(a, b)
+}
+
+// RolloutStatusTool 等待指定集群中 Deployment 的 rollout 完成或超时
+func RolloutStatusTool(proxy, clusterName, namespace, name string, timeout time.Duration) (*RolloutStatus, error) {
+	clientset, err := GetClusterClientset(proxy, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	return GetRolloutStatus(clientset, namespace, name, timeout)
+}
+
+// RolloutHistoryTool 查询指定集群中 Deployment 的版本历史
+func RolloutHistoryTool(proxy, clusterName, namespace, name string) ([]RolloutRevision, error) {
+	clientset, err := GetClusterClientset(proxy, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	return GetRolloutHistory(clientset, namespace, name)
+}
+
+// RolloutUndoTool 回滚指定集群中的 Deployment
+func RolloutUndoTool(proxy, clusterName, namespace, name string, toRevision int64, dryRun bool) (*RollbackResult, error) {
+	clientset, err := GetClusterClientset(proxy, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	return RollbackDeployment(clientset, namespace, name, toRevision, dryRun)
+}
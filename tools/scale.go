@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ScaleKinds scale_workload 支持的资源类型
+var ScaleKinds = []string{"Deployment", "StatefulSet"}
+
+// scaleKindAliases 资源类型别名（小写）-> 标准类型名
+var scaleKindAliases = map[string]string{
+	"deployment": "Deployment", "deployments": "Deployment", "deploy": "Deployment",
+	"statefulset": "StatefulSet", "statefulsets": "StatefulSet", "sts": "StatefulSet",
+}
+
+// NormalizeScaleKind 将 scale_workload 的资源类型或别名转换为标准类型名，不支持时返回错误
+func NormalizeScaleKind(kind string) (string, error) {
+	if k, ok := scaleKindAliases[strings.ToLower(kind)]; ok {
+		return k, nil
+	}
+	return "", fmt.Errorf("unsupported kind %s, supported: %s", kind, strings.Join(ScaleKinds, ", "))
+}
+
+// ScaleResult 扩缩容结果
+type ScaleResult struct {
+	Kind             string `json:"kind"`
+	Namespace        string `json:"namespace"`
+	Name             string `json:"name"`
+	PreviousReplicas int32  `json:"previous_replicas"`
+	Replicas         int32  `json:"replicas"`
+	MutationPreview
+}
+
+// ScaleWorkload 通过 scale 子资源调整 Deployment/StatefulSet 的副本数，返回调整前后的副本数
+// dryRun 时仅做服务端 dry-run，返回 scale 子资源变更前后的 diff
+func ScaleWorkload(clientset *kubernetes.Clientset, kind, namespace, name string, replicas int32, dryRun bool) (*ScaleResult, error) {
+	if replicas < 0 {
+		return nil, fmt.Errorf("replicas must not be negative")
+	}
+	kind, err := NormalizeScaleKind(kind)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	var getScale func() (*autoscalingv1.Scale, error)
+	var updateScale func(*autoscalingv1.Scale) (*autoscalingv1.Scale, error)
+	switch kind {
+	case "Deployment":
+		c := clientset.AppsV1().Deployments(namespace)
+		getScale = func() (*autoscalingv1.Scale, error) { return c.GetScale(ctx, name, metav1.GetOptions{}) }
+		updateScale = func(s *autoscalingv1.Scale) (*autoscalingv1.Scale, error) {
+			return c.UpdateScale(ctx, name, s, updateOptions(dryRun))
+		}
+	case "StatefulSet":
+		c := clientset.AppsV1().StatefulSets(namespace)
+		getScale = func() (*autoscalingv1.Scale, error) { return c.GetScale(ctx, name, metav1.GetOptions{}) }
+		updateScale = func(s *autoscalingv1.Scale) (*autoscalingv1.Scale, error) {
+			return c.UpdateScale(ctx, name, s, updateOptions(dryRun))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported kind %s, supported: %s", kind, strings.Join(ScaleKinds, ", "))
+	}
+	scale, err := getScale()
+	if err != nil {
+		return nil, err
+	}
+	result := &ScaleResult{Kind: kind, Namespace: namespace, Name: name, PreviousReplicas: scale.Spec.Replicas}
+	before := scale.DeepCopy()
+	// 带 resourceVersion 更新，期间副本数被他人修改时返回冲突错误
+	scale.Spec.Replicas = replicas
+	updated, err := updateScale(scale)
+	if err != nil {
+		return nil, err
+	}
+	result.Replicas = updated.Spec.Replicas
+	result.MutationPreview, err = newMutationPreview(strings.ToLower(kind)+"/"+name+"/scale", before, updated, dryRun)
+	return result, err
+}
+
+// ScaleWorkloadTool 调整指定集群中 Deployment/StatefulSet 的副本数
+func ScaleWorkloadTool(proxy, clusterName, kind, namespace, name string, replicas int32, dryRun bool) (*ScaleResult, error) {
+	clientset, err := GetClusterClientset(proxy, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	return ScaleWorkload(clientset, kind, namespace, name, replicas, dryRun)
+}